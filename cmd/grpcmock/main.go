@@ -0,0 +1,121 @@
+// Command grpcmock is a CLI for working with grpcmock stubs outside of the mock servers.
+//
+// Usage:
+//
+//	grpcmock stubs lint --descriptor set.binpb --dir stubs/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/torqio/grpcmock/pkg/dynamicmock"
+	"github.com/torqio/grpcmock/pkg/stub"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const usage = `Usage: grpcmock <command> [flags]
+
+Commands:
+  stubs lint    Validate the stubs of a directory against the services of a descriptor set
+`
+
+// errUsage is returned for invalid command lines, after their usage was printed
+var errUsage = errors.New("invalid usage")
+
+// errIssues is returned when a command completed, but found issues it already reported
+var errIssues = errors.New("issues found")
+
+type command func(args []string) error
+
+var commands = map[string]command{
+	"stubs lint": stubsLint,
+}
+
+func main() {
+	err := run(os.Args[1:])
+	switch {
+	case err == nil:
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case errors.Is(err, errIssues):
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	for name, cmd := range commands {
+		words := strings.Fields(name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == name {
+			return cmd(args[len(words):])
+		}
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return errUsage
+}
+
+func stubsLint(args []string) error {
+	flags := flag.NewFlagSet("grpcmock stubs lint", flag.ContinueOnError)
+	descriptor := flags.String("descriptor", "", "Path of a binary encoded file descriptor set of the mocked services, e.g. the output of `buf build -o set.binpb`")
+	dir := flags.String("dir", "", "Directory of the stubs")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if *descriptor == "" || *dir == "" {
+		fmt.Fprintln(os.Stderr, "Both --descriptor and --dir are required")
+		flags.Usage()
+		return errUsage
+	}
+
+	services, err := loadServices(*descriptor)
+	if err != nil {
+		return err
+	}
+	stubs, err := stub.MapStubFiles(*dir)
+	if err != nil {
+		return fmt.Errorf("map stub files: %w", err)
+	}
+
+	issues := stub.Lint(stubs, services...)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	count := 0
+	for _, methodStubs := range stubs {
+		count += len(methodStubs)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "Found %d issue(s) in %d stub(s)\n", len(issues), count)
+		return errIssues
+	}
+	fmt.Fprintf(os.Stderr, "All %d stub(s) are valid\n", count)
+	return nil
+}
+
+// loadServices returns all the services of the descriptor set in the given path
+func loadServices(path string) ([]protoreflect.ServiceDescriptor, error) {
+	set, err := dynamicmock.LoadDescriptorSet(path)
+	if err != nil {
+		return nil, err
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("create files from descriptor set: %w", err)
+	}
+
+	var services []protoreflect.ServiceDescriptor
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			services = append(services, fd.Services().Get(i))
+		}
+		return true
+	})
+	return services, nil
+}
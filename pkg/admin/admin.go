@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/torqio/grpcmock/pkg/admin/adminv1"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// HTTPPathPrefix is the path prefix of the HTTP/JSON API. Each AdminService method is served on
+// HTTPPathPrefix + <method name> (e.g. "/v1/AddExpectation"), with the JSON form of its request as the body.
+const HTTPPathPrefix = "/v1/"
+
+// Server implements the grpcmock.admin.v1.AdminService, configuring the methods of a mocker at runtime.
+// The mocker methods must be registered (see mocker.Mocker.RegisterService) for the server to be able to build their
+// requests and responses from JSON.
+type Server struct {
+	adminv1.UnimplementedAdminServiceServer
+	mocker *mocker.Mocker
+}
+
+// NewServer creates a new admin Server configuring the given mocker
+func NewServer(m *mocker.Mocker) *Server {
+	return &Server{mocker: m}
+}
+
+// RegisterGRPC registers the admin service on the given gRPC server
+func (s *Server) RegisterGRPC(srv *grpc.Server) error {
+	adminv1.RegisterAdminServiceServer(srv, s)
+	return nil
+}
+
+func (s *Server) ListMethods(_ context.Context, _ *adminv1.ListMethodsRequest) (*adminv1.ListMethodsResponse, error) {
+	res := &adminv1.ListMethodsResponse{}
+	for _, md := range s.mocker.Methods() {
+		res.Methods = append(res.Methods, mocker.MethodName(md))
+	}
+	return res, nil
+}
+
+func (s *Server) AddExpectation(_ context.Context, req *adminv1.AddExpectationRequest) (*adminv1.AddExpectationResponse, error) {
+	expectation := req.GetExpectation()
+	md, err := s.methodDescriptor(expectation.GetMethod())
+	if err != nil {
+		return nil, err
+	}
+
+	returns, err := returnValues(md, expectation.GetReturn())
+	if err != nil {
+		return nil, err
+	}
+
+	requestMatcher := mocker.Any()
+	if len(expectation.GetRequest().GetFields()) > 0 {
+		requestJSON, err := protojson.Marshal(expectation.GetRequest())
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "marshal request: %v", err)
+		}
+		requestMatcher = mocker.JSONSubset(requestJSON)
+	}
+
+	// Same arguments order as the generated mock servers
+	args := []any{mocker.Any(), requestMatcher}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		args = []any{requestMatcher, mocker.Any()}
+	}
+
+	call := s.mocker.AddExpectedCallV2(expectation.GetMethod(), args, returns)
+	return &adminv1.AddExpectationResponse{Id: call.ID()}, nil
+}
+
+func (s *Server) DeleteExpectation(_ context.Context, req *adminv1.DeleteExpectationRequest) (*adminv1.DeleteExpectationResponse, error) {
+	if _, err := s.methodDescriptor(req.GetMethod()); err != nil {
+		return nil, err
+	}
+	s.mocker.DeleteCall(req.GetMethod(), req.GetId())
+	return &adminv1.DeleteExpectationResponse{}, nil
+}
+
+func (s *Server) SetDefault(_ context.Context, req *adminv1.SetDefaultRequest) (*adminv1.SetDefaultResponse, error) {
+	md, err := s.methodDescriptor(req.GetMethod())
+	if err != nil {
+		return nil, err
+	}
+
+	returns, err := returnValues(md, req.GetReturn())
+	if err != nil {
+		return nil, err
+	}
+	s.mocker.SetDefaultCall(req.GetMethod(), returns)
+	return &adminv1.SetDefaultResponse{}, nil
+}
+
+func (s *Server) UnsetDefault(_ context.Context, req *adminv1.UnsetDefaultRequest) (*adminv1.UnsetDefaultResponse, error) {
+	if _, err := s.methodDescriptor(req.GetMethod()); err != nil {
+		return nil, err
+	}
+	s.mocker.UnsetDefaultCall(req.GetMethod())
+	return &adminv1.UnsetDefaultResponse{}, nil
+}
+
+func (s *Server) Reset(_ context.Context, req *adminv1.ResetRequest) (*adminv1.ResetResponse, error) {
+	if req.GetMethod() == "" {
+		s.mocker.ResetAll()
+		return &adminv1.ResetResponse{}, nil
+	}
+
+	if _, err := s.methodDescriptor(req.GetMethod()); err != nil {
+		return nil, err
+	}
+	s.mocker.ResetCall(req.GetMethod())
+	return &adminv1.ResetResponse{}, nil
+}
+
+func (s *Server) GetCallCount(_ context.Context, req *adminv1.GetCallCountRequest) (*adminv1.GetCallCountResponse, error) {
+	if _, err := s.methodDescriptor(req.GetMethod()); err != nil {
+		return nil, err
+	}
+	return &adminv1.GetCallCountResponse{Count: int32(s.mocker.GetCallCount(req.GetMethod()))}, nil
+}
+
+func (s *Server) ListCalls(_ context.Context, req *adminv1.ListCallsRequest) (*adminv1.ListCallsResponse, error) {
+	res := &adminv1.ListCallsResponse{}
+	for _, call := range s.mocker.Calls() {
+		if req.GetMethod() != "" && call.Method != req.GetMethod() {
+			continue
+		}
+
+		adminCall := &adminv1.Call{
+			Method:  call.Method,
+			Time:    timestamppb.New(call.Time),
+			Matched: call.Matched,
+		}
+		for _, arg := range call.Args {
+			msg, ok := arg.(proto.Message)
+			if !ok {
+				continue
+			}
+			request, err := mocker.MessageToStruct(msg)
+			if err != nil {
+				log.Printf("Failed converting the request of a call of %v to JSON: %v\n", call.Method, err)
+			}
+			adminCall.Request = request
+			break
+		}
+		res.Calls = append(res.Calls, adminCall)
+	}
+	return res, nil
+}
+
+func (s *Server) methodDescriptor(method string) (protoreflect.MethodDescriptor, error) {
+	md, ok := s.mocker.MethodDescriptor(method)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown method %q. Methods must be given by their full gRPC method name, e.g. \"/pkg.Service/Method\"", method)
+	}
+	return md, nil
+}
+
+// returnValues converts an admin Return to the return values of the given method, as expected by the mock servers
+func returnValues(md protoreflect.MethodDescriptor, ret *adminv1.Return) ([]any, error) {
+	var retErr error
+	if ret.GetStatus() != nil {
+		code := codes.Unknown
+		if ret.GetStatus().GetCode() != "" {
+			if err := code.UnmarshalJSON([]byte(fmt.Sprintf("%q", ret.GetStatus().GetCode()))); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid status code %q", ret.GetStatus().GetCode())
+			}
+		}
+		retErr = status.Error(code, ret.GetStatus().GetMessage())
+	}
+
+	responses := make([]proto.Message, 0, len(ret.GetResponses()))
+	for i, response := range ret.GetResponses() {
+		responseJSON, err := protojson.Marshal(response)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "marshal response #%d: %v", i, err)
+		}
+		msg := mocker.NewMessage(md.Output())
+		if err = protojson.Unmarshal(responseJSON, msg); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "unmarshal response #%d into %v: %v", i, md.Output().FullName(), err)
+		}
+		responses = append(responses, msg)
+	}
+
+	if md.IsStreamingServer() {
+		// The generated mock servers expect a slice of the concrete response type
+		results := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(mocker.NewMessage(md.Output()))), 0, len(responses))
+		for _, msg := range responses {
+			results = reflect.Append(results, reflect.ValueOf(msg))
+		}
+		return []any{results.Interface(), retErr}, nil
+	}
+
+	if len(responses) == 0 {
+		if retErr != nil {
+			return []any{nil, retErr}, nil
+		}
+		responses = append(responses, mocker.NewMessage(md.Output()))
+	}
+	return []any{responses[0], retErr}, nil
+}
+
+var httpStatusCodes = map[codes.Code]int{
+	codes.InvalidArgument: http.StatusBadRequest,
+	codes.NotFound:        http.StatusNotFound,
+	codes.Unimplemented:   http.StatusNotImplemented,
+}
+
+// httpError is the JSON body of the HTTP responses of failed admin calls
+type httpError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// HTTPHandler returns an HTTP handler serving the admin service as a JSON API. Mount it on HTTPPathPrefix.
+func (s *Server) HTTPHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		methodName := strings.TrimPrefix(r.URL.Path, HTTPPathPrefix)
+		var handler func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error)
+		for _, method := range adminv1.AdminService_ServiceDesc.Methods {
+			if method.MethodName == methodName {
+				handler = method.Handler
+				break
+			}
+		}
+		if handler == nil {
+			http.Error(w, fmt.Sprintf("unknown admin method %q", methodName), http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("read body: %v", err), http.StatusBadRequest)
+			return
+		}
+		dec := func(req any) error {
+			if len(body) == 0 {
+				return nil
+			}
+			if err := protojson.Unmarshal(body, req.(proto.Message)); err != nil {
+				return status.Errorf(codes.InvalidArgument, "unmarshal request: %v", err)
+			}
+			return nil
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		res, err := handler(s, r.Context(), dec, nil)
+		if err != nil {
+			st := status.Convert(err)
+			httpStatus, ok := httpStatusCodes[st.Code()]
+			if !ok {
+				httpStatus = http.StatusInternalServerError
+			}
+			w.WriteHeader(httpStatus)
+			// Marshalling strings can't fail
+			errJSON, _ := json.Marshal(httpError{Code: st.Code().String(), Message: st.Message()})
+			_, _ = w.Write(errJSON)
+			return
+		}
+
+		resJSON, err := protojson.Marshal(res.(proto.Message))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("marshal response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(resJSON)
+	})
+}
@@ -0,0 +1,409 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: grpcmock/admin/v1/admin.proto
+
+package adminv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AdminService_ListMethods_FullMethodName       = "/grpcmock.admin.v1.AdminService/ListMethods"
+	AdminService_AddExpectation_FullMethodName    = "/grpcmock.admin.v1.AdminService/AddExpectation"
+	AdminService_DeleteExpectation_FullMethodName = "/grpcmock.admin.v1.AdminService/DeleteExpectation"
+	AdminService_SetDefault_FullMethodName        = "/grpcmock.admin.v1.AdminService/SetDefault"
+	AdminService_UnsetDefault_FullMethodName      = "/grpcmock.admin.v1.AdminService/UnsetDefault"
+	AdminService_Reset_FullMethodName             = "/grpcmock.admin.v1.AdminService/Reset"
+	AdminService_GetCallCount_FullMethodName      = "/grpcmock.admin.v1.AdminService/GetCallCount"
+	AdminService_ListCalls_FullMethodName         = "/grpcmock.admin.v1.AdminService/ListCalls"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AdminService configures a running mock server at runtime.
+// All the methods are referenced by their full gRPC method name (e.g. "/pkg.ExampleService/ExampleMethod").
+type AdminServiceClient interface {
+	// ListMethods lists all the methods served by the mock server.
+	ListMethods(ctx context.Context, in *ListMethodsRequest, opts ...grpc.CallOption) (*ListMethodsResponse, error)
+	// AddExpectation adds an expected call to a method.
+	AddExpectation(ctx context.Context, in *AddExpectationRequest, opts ...grpc.CallOption) (*AddExpectationResponse, error)
+	// DeleteExpectation deletes an expected call previously added with AddExpectation.
+	DeleteExpectation(ctx context.Context, in *DeleteExpectationRequest, opts ...grpc.CallOption) (*DeleteExpectationResponse, error)
+	// SetDefault sets the default return value of a method.
+	SetDefault(ctx context.Context, in *SetDefaultRequest, opts ...grpc.CallOption) (*SetDefaultResponse, error)
+	// UnsetDefault deletes the default return value of a method.
+	UnsetDefault(ctx context.Context, in *UnsetDefaultRequest, opts ...grpc.CallOption) (*UnsetDefaultResponse, error)
+	// Reset deletes the expected calls, the default return value and the call count of a method, or of all the methods.
+	Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*ResetResponse, error)
+	// GetCallCount returns how many times a method was called.
+	GetCallCount(ctx context.Context, in *GetCallCountRequest, opts ...grpc.CallOption) (*GetCallCountResponse, error)
+	// ListCalls returns the calls received by the mock server, in the order they were received.
+	ListCalls(ctx context.Context, in *ListCallsRequest, opts ...grpc.CallOption) (*ListCallsResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) ListMethods(ctx context.Context, in *ListMethodsRequest, opts ...grpc.CallOption) (*ListMethodsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMethodsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListMethods_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) AddExpectation(ctx context.Context, in *AddExpectationRequest, opts ...grpc.CallOption) (*AddExpectationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddExpectationResponse)
+	err := c.cc.Invoke(ctx, AdminService_AddExpectation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DeleteExpectation(ctx context.Context, in *DeleteExpectationRequest, opts ...grpc.CallOption) (*DeleteExpectationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteExpectationResponse)
+	err := c.cc.Invoke(ctx, AdminService_DeleteExpectation_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetDefault(ctx context.Context, in *SetDefaultRequest, opts ...grpc.CallOption) (*SetDefaultResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetDefaultResponse)
+	err := c.cc.Invoke(ctx, AdminService_SetDefault_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) UnsetDefault(ctx context.Context, in *UnsetDefaultRequest, opts ...grpc.CallOption) (*UnsetDefaultResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnsetDefaultResponse)
+	err := c.cc.Invoke(ctx, AdminService_UnsetDefault_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) Reset(ctx context.Context, in *ResetRequest, opts ...grpc.CallOption) (*ResetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResetResponse)
+	err := c.cc.Invoke(ctx, AdminService_Reset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetCallCount(ctx context.Context, in *GetCallCountRequest, opts ...grpc.CallOption) (*GetCallCountResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCallCountResponse)
+	err := c.cc.Invoke(ctx, AdminService_GetCallCount_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListCalls(ctx context.Context, in *ListCallsRequest, opts ...grpc.CallOption) (*ListCallsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCallsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListCalls_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility.
+//
+// AdminService configures a running mock server at runtime.
+// All the methods are referenced by their full gRPC method name (e.g. "/pkg.ExampleService/ExampleMethod").
+type AdminServiceServer interface {
+	// ListMethods lists all the methods served by the mock server.
+	ListMethods(context.Context, *ListMethodsRequest) (*ListMethodsResponse, error)
+	// AddExpectation adds an expected call to a method.
+	AddExpectation(context.Context, *AddExpectationRequest) (*AddExpectationResponse, error)
+	// DeleteExpectation deletes an expected call previously added with AddExpectation.
+	DeleteExpectation(context.Context, *DeleteExpectationRequest) (*DeleteExpectationResponse, error)
+	// SetDefault sets the default return value of a method.
+	SetDefault(context.Context, *SetDefaultRequest) (*SetDefaultResponse, error)
+	// UnsetDefault deletes the default return value of a method.
+	UnsetDefault(context.Context, *UnsetDefaultRequest) (*UnsetDefaultResponse, error)
+	// Reset deletes the expected calls, the default return value and the call count of a method, or of all the methods.
+	Reset(context.Context, *ResetRequest) (*ResetResponse, error)
+	// GetCallCount returns how many times a method was called.
+	GetCallCount(context.Context, *GetCallCountRequest) (*GetCallCountResponse, error)
+	// ListCalls returns the calls received by the mock server, in the order they were received.
+	ListCalls(context.Context, *ListCallsRequest) (*ListCallsResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServiceServer struct{}
+
+func (UnimplementedAdminServiceServer) ListMethods(context.Context, *ListMethodsRequest) (*ListMethodsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMethods not implemented")
+}
+func (UnimplementedAdminServiceServer) AddExpectation(context.Context, *AddExpectationRequest) (*AddExpectationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddExpectation not implemented")
+}
+func (UnimplementedAdminServiceServer) DeleteExpectation(context.Context, *DeleteExpectationRequest) (*DeleteExpectationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteExpectation not implemented")
+}
+func (UnimplementedAdminServiceServer) SetDefault(context.Context, *SetDefaultRequest) (*SetDefaultResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetDefault not implemented")
+}
+func (UnimplementedAdminServiceServer) UnsetDefault(context.Context, *UnsetDefaultRequest) (*UnsetDefaultResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnsetDefault not implemented")
+}
+func (UnimplementedAdminServiceServer) Reset(context.Context, *ResetRequest) (*ResetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reset not implemented")
+}
+func (UnimplementedAdminServiceServer) GetCallCount(context.Context, *GetCallCountRequest) (*GetCallCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCallCount not implemented")
+}
+func (UnimplementedAdminServiceServer) ListCalls(context.Context, *ListCallsRequest) (*ListCallsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCalls not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+func (UnimplementedAdminServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_ListMethods_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMethodsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListMethods(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListMethods_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListMethods(ctx, req.(*ListMethodsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_AddExpectation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddExpectationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).AddExpectation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_AddExpectation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).AddExpectation(ctx, req.(*AddExpectationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DeleteExpectation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteExpectationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DeleteExpectation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_DeleteExpectation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DeleteExpectation(ctx, req.(*DeleteExpectationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetDefault_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetDefaultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetDefault(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetDefault_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetDefault(ctx, req.(*SetDefaultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_UnsetDefault_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsetDefaultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).UnsetDefault(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_UnsetDefault_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).UnsetDefault(ctx, req.(*UnsetDefaultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_Reset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).Reset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_Reset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).Reset(ctx, req.(*ResetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetCallCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCallCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetCallCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetCallCount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetCallCount(ctx, req.(*GetCallCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListCalls_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCallsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListCalls(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListCalls_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListCalls(ctx, req.(*ListCallsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpcmock.admin.v1.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListMethods",
+			Handler:    _AdminService_ListMethods_Handler,
+		},
+		{
+			MethodName: "AddExpectation",
+			Handler:    _AdminService_AddExpectation_Handler,
+		},
+		{
+			MethodName: "DeleteExpectation",
+			Handler:    _AdminService_DeleteExpectation_Handler,
+		},
+		{
+			MethodName: "SetDefault",
+			Handler:    _AdminService_SetDefault_Handler,
+		},
+		{
+			MethodName: "UnsetDefault",
+			Handler:    _AdminService_UnsetDefault_Handler,
+		},
+		{
+			MethodName: "Reset",
+			Handler:    _AdminService_Reset_Handler,
+		},
+		{
+			MethodName: "GetCallCount",
+			Handler:    _AdminService_GetCallCount_Handler,
+		},
+		{
+			MethodName: "ListCalls",
+			Handler:    _AdminService_ListCalls_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "grpcmock/admin/v1/admin.proto",
+}
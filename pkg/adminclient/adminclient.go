@@ -0,0 +1,197 @@
+package adminclient
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/torqio/grpcmock/pkg/admin/adminv1"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"github.com/torqio/grpcmock/pkg/stub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Client configures a standalone mock server (see the generate-cmds option) through its admin API.
+// Methods are referred to by their full gRPC method name, like in the mocker.
+type Client struct {
+	admin adminv1.AdminServiceClient
+	t     *testing.T
+}
+
+// Option configures a Client
+type Option func(c *Client)
+
+// WithT makes the client report the errors of calls which don't return an error (e.g. of the generated remote mocks
+// fluent API) as test errors
+func WithT(t *testing.T) Option {
+	return func(c *Client) {
+		c.t = t
+	}
+}
+
+// New creates a new Client using the given connection to the standalone mock server
+func New(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	c := &Client{admin: adminv1.NewAdminServiceClient(conn)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Admin returns the underlying admin service client
+func (c *Client) Admin() adminv1.AdminServiceClient {
+	return c.admin
+}
+
+// LogError reports the given error through the test set by WithT, if any
+func (c *Client) LogError(err error) {
+	if c.t == nil {
+		return
+	}
+	c.t.Helper()
+	c.t.Errorf("grpcmock admin ERROR: %v", err)
+}
+
+// Expectation is an expected call added to the standalone mock server, the remote equivalent of mocker.RegisteredCall.
+// A nil Expectation (e.g. returned by the generated remote mocks when adding it failed) can still be deleted.
+type Expectation struct {
+	client *Client
+	method string
+	id     string
+}
+
+// ID returns the ID of the expectation, or an empty string for a nil Expectation
+func (e *Expectation) ID() string {
+	if e == nil {
+		return ""
+	}
+	return e.id
+}
+
+// Delete deletes the expectation from the mock server. Deleting a nil Expectation does nothing.
+func (e *Expectation) Delete() {
+	if e == nil {
+		return
+	}
+	if err := e.client.DeleteExpectation(context.Background(), e.method, e.id); err != nil {
+		e.client.LogError(err)
+	}
+}
+
+// AddExpectation adds an expected call of the given method. The call matches requests that are equal to, or a
+// superset of, req. req may be a proto message, nil or mocker.Any() to match any request.
+// The call returns the given responses (more than one only for server streaming methods) and err, which is sent as its
+// gRPC status.
+func (c *Client) AddExpectation(ctx context.Context, method string, req any, responses []proto.Message, err error) (*Expectation, error) {
+	expectation := &adminv1.Expectation{Method: method}
+	switch r := req.(type) {
+	case nil:
+	case proto.Message:
+		s, convertErr := mocker.MessageToStruct(r)
+		if convertErr != nil {
+			return nil, fmt.Errorf("convert request: %w", convertErr)
+		}
+		expectation.Request = s
+	default:
+		if !mocker.IsAny(req) {
+			return nil, fmt.Errorf("unsupported request of type %T, only proto messages and mocker.Any() can be sent to a remote mock server", req)
+		}
+	}
+
+	ret, convertErr := newReturn(responses, err)
+	if convertErr != nil {
+		return nil, convertErr
+	}
+	expectation.Return = ret
+
+	res, adminErr := c.admin.AddExpectation(ctx, &adminv1.AddExpectationRequest{Expectation: expectation})
+	if adminErr != nil {
+		return nil, fmt.Errorf("add expectation of %v: %w", method, adminErr)
+	}
+	return &Expectation{client: c, method: method, id: res.GetId()}, nil
+}
+
+// DeleteExpectation deletes the expected call with the given ID
+func (c *Client) DeleteExpectation(ctx context.Context, method, id string) error {
+	if _, err := c.admin.DeleteExpectation(ctx, &adminv1.DeleteExpectationRequest{Method: method, Id: id}); err != nil {
+		return fmt.Errorf("delete expectation %v of %v: %w", id, method, err)
+	}
+	return nil
+}
+
+// SetDefault sets the default return values of the given method, used when no expected call or file stub matches
+func (c *Client) SetDefault(ctx context.Context, method string, responses []proto.Message, err error) error {
+	ret, convertErr := newReturn(responses, err)
+	if convertErr != nil {
+		return convertErr
+	}
+	if _, adminErr := c.admin.SetDefault(ctx, &adminv1.SetDefaultRequest{Method: method, Return: ret}); adminErr != nil {
+		return fmt.Errorf("set default of %v: %w", method, adminErr)
+	}
+	return nil
+}
+
+// UnsetDefault removes the default return values of the given method
+func (c *Client) UnsetDefault(ctx context.Context, method string) error {
+	if _, err := c.admin.UnsetDefault(ctx, &adminv1.UnsetDefaultRequest{Method: method}); err != nil {
+		return fmt.Errorf("unset default of %v: %w", method, err)
+	}
+	return nil
+}
+
+// Reset removes the expected calls and default of the given method
+func (c *Client) Reset(ctx context.Context, method string) error {
+	if _, err := c.admin.Reset(ctx, &adminv1.ResetRequest{Method: method}); err != nil {
+		return fmt.Errorf("reset %v: %w", method, err)
+	}
+	return nil
+}
+
+// ResetAll removes the expected calls and defaults of all the methods, and the recorded calls
+func (c *Client) ResetAll(ctx context.Context) error {
+	if _, err := c.admin.Reset(ctx, &adminv1.ResetRequest{}); err != nil {
+		return fmt.Errorf("reset all: %w", err)
+	}
+	return nil
+}
+
+// CallCount returns how many times the given method was called
+func (c *Client) CallCount(ctx context.Context, method string) (int, error) {
+	res, err := c.admin.GetCallCount(ctx, &adminv1.GetCallCountRequest{Method: method})
+	if err != nil {
+		return 0, fmt.Errorf("get call count of %v: %w", method, err)
+	}
+	return int(res.GetCount()), nil
+}
+
+// Calls returns the calls recorded by the mock server, in the order they were received.
+// An empty method returns the calls of all the methods.
+func (c *Client) Calls(ctx context.Context, method string) ([]*adminv1.Call, error) {
+	res, err := c.admin.ListCalls(ctx, &adminv1.ListCallsRequest{Method: method})
+	if err != nil {
+		return nil, fmt.Errorf("list calls: %w", err)
+	}
+	return res.GetCalls(), nil
+}
+
+func newReturn(responses []proto.Message, err error) (*adminv1.Return, error) {
+	ret := &adminv1.Return{}
+	for i, response := range responses {
+		if response == nil || !response.ProtoReflect().IsValid() {
+			continue
+		}
+		s, convertErr := mocker.MessageToStruct(response)
+		if convertErr != nil {
+			return nil, fmt.Errorf("convert response #%d: %w", i, convertErr)
+		}
+		ret.Responses = append(ret.Responses, s)
+	}
+
+	if err != nil {
+		st := status.Convert(err)
+		ret.Status = &adminv1.Status{Code: stub.CodeName(st.Code()), Message: st.Message()}
+	}
+	return ret, nil
+}
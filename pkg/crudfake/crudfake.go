@@ -0,0 +1,536 @@
+// Package crudfake serves the standard methods of resource-oriented services (see https://google.aip.dev/121) from an
+// in-memory store of their resources, so tests of CRUD flows don't have to mock every call.
+package crudfake
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DefaultPageSize is the page size of List calls which don't set one
+const DefaultPageSize = 50
+
+type methodKind int
+
+const (
+	methodGet methodKind = iota + 1
+	methodList
+	methodCreate
+	methodUpdate
+	methodDelete
+)
+
+// standardMethod is a standard method of a resource, detected by its name and messages
+type standardMethod struct {
+	kind     methodKind
+	resource *resource
+	// resourceField is the request field holding the resource, for Create and Update
+	resourceField protoreflect.FieldDescriptor
+	// resourcesField is the response field of the resources, for List
+	resourcesField protoreflect.FieldDescriptor
+}
+
+// resource is a message type with a `name` field, served by standard methods
+type resource struct {
+	desc protoreflect.MessageDescriptor
+	// collection is the collection ID of the resource names, e.g. books in publishers/123/books/456
+	collection string
+}
+
+// Fake is an in-memory fake of the standard methods of a service. The methods are detected by their names and
+// messages: Get<Resource>, List<Resources>, Create<Resource>, Update<Resource> and Delete<Resource>, where the
+// resource message has a `name` field (and optionally a google.api.resource annotation, whose pattern gives the
+// collection ID of the resource names).
+//
+// Create stores the resource under parent/collection/id (id is the `<resource>_id` field, or a random UUID), Get and
+// Delete find it by name, List pages the resources of the parent by name order with opaque page tokens, and Update
+// applies the `update_mask` field mask (all the set fields without a mask, the whole resource with "*"), creating the
+// resource if `allow_missing` is set. `create_time` and `update_time` timestamp fields are set with the clock of the
+// mocker of the call (see mocker.ClockFromContext).
+type Fake struct {
+	// methods are the standard methods by their full gRPC method name
+	methods map[string]*standardMethod
+
+	mu sync.Mutex
+	// resources are the stored resources by their type and then their name
+	resources map[protoreflect.FullName]map[string]proto.Message
+}
+
+// New creates an empty fake of the standard methods of the service
+func New(sd protoreflect.ServiceDescriptor) *Fake {
+	f := &Fake{methods: make(map[string]*standardMethod), resources: make(map[protoreflect.FullName]map[string]proto.Message)}
+
+	// The resources by their full name, in the order of their methods
+	resources := make(map[protoreflect.FullName]*resource)
+	var ordered []*resource
+	resourceOf := func(md protoreflect.MessageDescriptor) *resource {
+		if r, ok := resources[md.FullName()]; ok {
+			return r
+		}
+		r := &resource{desc: md, collection: patternCollection(md)}
+		resources[md.FullName()] = r
+		ordered = append(ordered, r)
+		return r
+	}
+
+	methods := sd.Methods()
+	var deletes []protoreflect.MethodDescriptor
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if md.IsStreamingClient() || md.IsStreamingServer() {
+			continue
+		}
+		name := string(md.Name())
+		switch {
+		case strings.HasPrefix(name, "Get") && isResource(md.Output()) && md.Output().Name() == protoreflect.Name(name[3:]) && hasStringField(md.Input(), "name"):
+			f.methods[mocker.MethodName(md)] = &standardMethod{kind: methodGet, resource: resourceOf(md.Output())}
+		case strings.HasPrefix(name, "List"):
+			fd := resourcesField(md.Output())
+			if fd == nil || !hasStringField(md.Output(), "next_page_token") {
+				continue
+			}
+			r := resourceOf(fd.Message())
+			if r.collection == "" {
+				r.collection = lowerFirst(name[4:])
+			}
+			f.methods[mocker.MethodName(md)] = &standardMethod{kind: methodList, resource: r, resourcesField: fd}
+		case strings.HasPrefix(name, "Create") || strings.HasPrefix(name, "Update"):
+			kind, resourceName := methodCreate, name[6:]
+			if strings.HasPrefix(name, "Update") {
+				kind = methodUpdate
+			}
+			fd := messageField(md.Input(), md.Output())
+			if !isResource(md.Output()) || md.Output().Name() != protoreflect.Name(resourceName) || fd == nil {
+				continue
+			}
+			f.methods[mocker.MethodName(md)] = &standardMethod{kind: kind, resource: resourceOf(md.Output()), resourceField: fd}
+		case strings.HasPrefix(name, "Delete") && hasStringField(md.Input(), "name"):
+			deletes = append(deletes, md)
+		}
+	}
+	// Delete methods don't return the resource, so they're matched by name with the resources of the other methods
+	for _, md := range deletes {
+		for _, r := range ordered {
+			if r.desc.Name() == protoreflect.Name(strings.TrimPrefix(string(md.Name()), "Delete")) {
+				f.methods[mocker.MethodName(md)] = &standardMethod{kind: methodDelete, resource: r}
+				break
+			}
+		}
+	}
+	for _, r := range ordered {
+		if r.collection == "" {
+			r.collection = lowerFirst(string(r.desc.Name())) + "s"
+		}
+	}
+	return f
+}
+
+// HasStandardMethods returns whether the service has standard methods, which its fake serves (see Fake)
+func HasStandardMethods(sd protoreflect.ServiceDescriptor) bool {
+	return len(New(sd).methods) > 0
+}
+
+// Methods returns the full gRPC method names of the standard methods served by the fake, sorted
+func (f *Fake) Methods() []string {
+	methods := make([]string, 0, len(f.methods))
+	for method := range f.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Handle serves a call of a standard method, filling res with its response. Calls of other methods fail with an
+// Unimplemented status.
+func (f *Fake) Handle(ctx context.Context, method string, req, res proto.Message) error {
+	m, ok := f.methods[method]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "%v isn't a standard method of a resource", method)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := mocker.ClockFromContext(ctx).Now()
+	reqMsg := req.ProtoReflect()
+	switch m.kind {
+	case methodGet:
+		stored, err := f.find(m.resource, stringField(reqMsg, "name"))
+		if err != nil {
+			return err
+		}
+		proto.Merge(res, stored)
+	case methodList:
+		return f.list(m, reqMsg, res.ProtoReflect())
+	case methodCreate:
+		created, err := f.create(m, reqMsg, now)
+		if err != nil {
+			return err
+		}
+		proto.Merge(res, created)
+	case methodUpdate:
+		updated, err := f.update(m, reqMsg, now)
+		if err != nil {
+			return err
+		}
+		proto.Merge(res, updated)
+	case methodDelete:
+		name := stringField(reqMsg, "name")
+		deleted, err := f.find(m.resource, name)
+		if err != nil {
+			return err
+		}
+		delete(f.resources[m.resource.desc.FullName()], name)
+		// Delete methods return either the deleted resource, or an empty message
+		if res.ProtoReflect().Descriptor().FullName() == m.resource.desc.FullName() {
+			proto.Merge(res, deleted)
+		}
+	}
+	return nil
+}
+
+// Put stores resources, e.g. to seed the fake. The resources must have a name, and be of the type of a resource of
+// the standard methods.
+func (f *Fake) Put(resources ...proto.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range resources {
+		if !f.isResourceType(r.ProtoReflect().Descriptor()) {
+			return fmt.Errorf("%v isn't a resource of the standard methods", r.ProtoReflect().Descriptor().FullName())
+		}
+		name := stringField(r.ProtoReflect(), "name")
+		if name == "" {
+			return fmt.Errorf("%v resource has no name", r.ProtoReflect().Descriptor().FullName())
+		}
+		f.store(name, proto.Clone(r))
+	}
+	return nil
+}
+
+// Resources returns copies of all the stored resources, sorted by name
+func (f *Fake) Resources() []proto.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var resources []proto.Message
+	for _, byName := range f.resources {
+		for _, r := range byName {
+			resources = append(resources, proto.Clone(r))
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return stringField(resources[i].ProtoReflect(), "name") < stringField(resources[j].ProtoReflect(), "name")
+	})
+	return resources
+}
+
+// Reset deletes all the stored resources
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resources = make(map[protoreflect.FullName]map[string]proto.Message)
+}
+
+func (f *Fake) isResourceType(md protoreflect.MessageDescriptor) bool {
+	for _, m := range f.methods {
+		if m.resource.desc.FullName() == md.FullName() {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fake) store(name string, r proto.Message) {
+	typeName := r.ProtoReflect().Descriptor().FullName()
+	if f.resources[typeName] == nil {
+		f.resources[typeName] = make(map[string]proto.Message)
+	}
+	f.resources[typeName][name] = r
+}
+
+func (f *Fake) find(r *resource, name string) (proto.Message, error) {
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	stored, ok := f.resources[r.desc.FullName()][name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%v %q not found", r.desc.Name(), name)
+	}
+	return stored, nil
+}
+
+func (f *Fake) create(m *standardMethod, req protoreflect.Message, now time.Time) (proto.Message, error) {
+	created := proto.Clone(req.Get(m.resourceField).Message().Interface())
+	id := stringField(req, protoreflect.Name(snakeCase(string(m.resource.desc.Name()))+"_id"))
+	if id == "" {
+		id = uuid.NewString()
+	}
+	name := m.resource.collection + "/" + id
+	if parent := stringField(req, "parent"); parent != "" {
+		name = parent + "/" + name
+	}
+	if _, ok := f.resources[m.resource.desc.FullName()][name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "%v %q already exists", m.resource.desc.Name(), name)
+	}
+
+	msg := created.ProtoReflect()
+	msg.Set(msg.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString(name))
+	setTimestamp(msg, "create_time", now)
+	setTimestamp(msg, "update_time", now)
+	f.store(name, created)
+	return created, nil
+}
+
+func (f *Fake) update(m *standardMethod, req protoreflect.Message, now time.Time) (proto.Message, error) {
+	patch := req.Get(m.resourceField).Message()
+	name := stringField(patch, "name")
+	stored, err := f.find(m.resource, name)
+	if status.Code(err) == codes.NotFound && boolField(req, "allow_missing") {
+		created := proto.Clone(patch.Interface())
+		setTimestamp(created.ProtoReflect(), "create_time", now)
+		setTimestamp(created.ProtoReflect(), "update_time", now)
+		f.store(name, created)
+		return created, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	updated := proto.Clone(stored)
+	if err = applyMask(updated.ProtoReflect(), patch, updateMaskPaths(req)); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "update mask: %v", err)
+	}
+	setTimestamp(updated.ProtoReflect(), "update_time", now)
+	f.store(name, updated)
+	return updated, nil
+}
+
+func (f *Fake) list(m *standardMethod, req, res protoreflect.Message) error {
+	pageSize := int(intField(req, "page_size"))
+	if pageSize < 0 {
+		return status.Error(codes.InvalidArgument, "page size can't be negative")
+	}
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	var after string
+	if token := stringField(req, "page_token"); token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid page token %q", token)
+		}
+		after = string(decoded)
+	}
+
+	prefix := m.resource.collection + "/"
+	if parent := stringField(req, "parent"); parent != "" {
+		prefix = parent + "/" + prefix
+	}
+	var names []string
+	for name := range f.resources[m.resource.desc.FullName()] {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if totalSize := res.Descriptor().Fields().ByName("total_size"); totalSize != nil && totalSize.Kind() == protoreflect.Int32Kind {
+		res.Set(totalSize, protoreflect.ValueOfInt32(int32(len(names))))
+	}
+	start := sort.SearchStrings(names, after)
+	if start < len(names) && names[start] == after {
+		start++
+	}
+
+	list := res.Mutable(m.resourcesField).List()
+	end := start
+	for ; end < len(names) && end-start < pageSize; end++ {
+		list.Append(protoreflect.ValueOfMessage(proto.Clone(f.resources[m.resource.desc.FullName()][names[end]]).ProtoReflect()))
+	}
+	if end < len(names) {
+		token := base64.RawURLEncoding.EncodeToString([]byte(names[end-1]))
+		res.Set(res.Descriptor().Fields().ByName("next_page_token"), protoreflect.ValueOfString(token))
+	}
+	return nil
+}
+
+// applyMask sets the fields of paths from patch on msg. Without paths, the fields set in patch are set, and with "*",
+// all the fields are. The name of msg is kept.
+func applyMask(msg, patch protoreflect.Message, paths []string) error {
+	nameField := msg.Descriptor().Fields().ByName("name")
+	if len(paths) == 0 {
+		patch.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if fd != nameField {
+				msg.Set(fd, v)
+			}
+			return true
+		})
+		return nil
+	}
+
+	for _, path := range paths {
+		if path == "*" {
+			fields := msg.Descriptor().Fields()
+			for i := 0; i < fields.Len(); i++ {
+				if fd := fields.Get(i); fd != nameField && fd.Name() != "create_time" {
+					copyField(msg, patch, fd)
+				}
+			}
+			continue
+		}
+		if err := applyPath(msg, patch, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyPath(msg, patch protoreflect.Message, path string) error {
+	fieldName, rest, nested := strings.Cut(path, ".")
+	fd := msg.Descriptor().Fields().ByName(protoreflect.Name(fieldName))
+	if fd == nil {
+		return fmt.Errorf("%v has no field %q", msg.Descriptor().FullName(), fieldName)
+	}
+	if !nested {
+		copyField(msg, patch, fd)
+		return nil
+	}
+	if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+		return fmt.Errorf("field %q of %v isn't a message", fieldName, msg.Descriptor().FullName())
+	}
+	return applyPath(msg.Mutable(fd).Message(), patch.Get(fd).Message(), rest)
+}
+
+func copyField(msg, patch protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	if patch.Has(fd) {
+		msg.Set(fd, patch.Get(fd))
+	} else {
+		msg.Clear(fd)
+	}
+}
+
+// updateMaskPaths returns the paths of the `update_mask` field mask of an Update request
+func updateMaskPaths(req protoreflect.Message) []string {
+	fd := req.Descriptor().Fields().ByName("update_mask")
+	if fd == nil || fd.Message() == nil || fd.Message().FullName() != "google.protobuf.FieldMask" || !req.Has(fd) {
+		return nil
+	}
+	mask := req.Get(fd).Message()
+	paths := mask.Get(mask.Descriptor().Fields().ByName("paths")).List()
+	var maskPaths []string
+	for i := 0; i < paths.Len(); i++ {
+		maskPaths = append(maskPaths, paths.Get(i).String())
+	}
+	return maskPaths
+}
+
+// patternCollection returns the collection ID of the google.api.resource pattern of the message, if it has one, e.g.
+// books for publishers/{publisher}/books/{book}
+func patternCollection(md protoreflect.MessageDescriptor) string {
+	descriptor, ok := proto.GetExtension(md.Options(), annotations.E_Resource).(*annotations.ResourceDescriptor)
+	if !ok || len(descriptor.GetPattern()) == 0 {
+		return ""
+	}
+	segments := strings.Split(descriptor.GetPattern()[0], "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[len(segments)-2]
+}
+
+// isResource returns whether the message is a resource: it has a google.api.resource annotation or a `name` field
+func isResource(md protoreflect.MessageDescriptor) bool {
+	return patternCollection(md) != "" || hasStringField(md, "name")
+}
+
+// resourcesField returns the repeated resource field of a List response
+func resourcesField(md protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); fd.IsList() && fd.Message() != nil && hasStringField(fd.Message(), "name") {
+			return fd
+		}
+	}
+	return nil
+}
+
+// messageField returns the field of md of the type of field, if there is one
+func messageField(md, field protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); !fd.IsList() && !fd.IsMap() && fd.Message() != nil && fd.Message().FullName() == field.FullName() {
+			return fd
+		}
+	}
+	return nil
+}
+
+func hasStringField(md protoreflect.MessageDescriptor, name protoreflect.Name) bool {
+	fd := md.Fields().ByName(name)
+	return fd != nil && fd.Kind() == protoreflect.StringKind && !fd.IsList()
+}
+
+func stringField(msg protoreflect.Message, name protoreflect.Name) string {
+	if !hasStringField(msg.Descriptor(), name) {
+		return ""
+	}
+	return msg.Get(msg.Descriptor().Fields().ByName(name)).String()
+}
+
+func intField(msg protoreflect.Message, name protoreflect.Name) int64 {
+	fd := msg.Descriptor().Fields().ByName(name)
+	if fd == nil || fd.Kind() != protoreflect.Int32Kind {
+		return 0
+	}
+	return msg.Get(fd).Int()
+}
+
+func boolField(msg protoreflect.Message, name protoreflect.Name) bool {
+	fd := msg.Descriptor().Fields().ByName(name)
+	return fd != nil && fd.Kind() == protoreflect.BoolKind && msg.Get(fd).Bool()
+}
+
+// setTimestamp sets a google.protobuf.Timestamp field of the message, if it has one
+func setTimestamp(msg protoreflect.Message, name protoreflect.Name, t time.Time) {
+	fd := msg.Descriptor().Fields().ByName(name)
+	if fd == nil || fd.Message() == nil || fd.Message().FullName() != "google.protobuf.Timestamp" {
+		return
+	}
+	ts := msg.Mutable(fd).Message()
+	ts.Set(ts.Descriptor().Fields().ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+	ts.Set(ts.Descriptor().Fields().ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// snakeCase converts a message name to snake case, e.g. BookShelf to book_shelf
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
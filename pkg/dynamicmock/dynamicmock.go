@@ -0,0 +1,404 @@
+package dynamicmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"github.com/torqio/grpcmock/pkg/stub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Server is a mock server for every service of a set of proto files, built from their descriptors at runtime instead
+// of generated code. Requests and responses are dynamicpb messages.
+//
+// Its behavior is configured the same way as the generated mock servers, through its mocker (keyed by the full gRPC
+// method name), and through file stubs. The arguments of the expected calls are (ctx, req) for unary methods and
+// (req, stream) for streaming methods. The return values are (proto.Message, error) for methods with a single
+// response and ([]proto.Message, error) for server streaming methods.
+type Server struct {
+	mocker   *mocker.Mocker
+	services []protoreflect.ServiceDescriptor
+	stubs    stub.MethodFileStubs
+	// stubStore, if set, holds the file stubs instead of stubs
+	stubStore *stub.Store
+}
+
+// Option configures a Server
+type Option func(s *Server)
+
+// WithMocker makes the server use the given mocker instead of creating a new one
+func WithMocker(m *mocker.Mocker) Option {
+	return func(s *Server) {
+		s.mocker = m
+	}
+}
+
+// WithStubs makes the server look up file stubs for requests which have no matching expected call
+func WithStubs(stubs stub.MethodFileStubs) Option {
+	return func(s *Server) {
+		s.stubs = stubs
+	}
+}
+
+// WithStubStore is like WithStubs, with the current stubs of the given store, so a watched store (see stub.Store.Watch)
+// changes them live
+func WithStubStore(store *stub.Store) Option {
+	return func(s *Server) {
+		s.stubStore = store
+	}
+}
+
+// New creates a new Server for all the services in the given files
+func New(files *protoregistry.Files, opts ...Option) *Server {
+	s := &Server{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.mocker == nil {
+		s.mocker = mocker.NewMocker()
+	}
+
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			s.services = append(s.services, services.Get(i))
+		}
+		return true
+	})
+	sort.Slice(s.services, func(i, j int) bool {
+		return s.services[i].FullName() < s.services[j].FullName()
+	})
+
+	for _, sd := range s.services {
+		s.mocker.RegisterService(sd)
+	}
+	return s
+}
+
+// NewFromDescriptorSet creates a new Server for all the services in the given file descriptor set
+// (e.g. the output of `buf build -o set.binpb`)
+func NewFromDescriptorSet(set *descriptorpb.FileDescriptorSet, opts ...Option) (*Server, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("create files from descriptor set: %w", err)
+	}
+	return New(files, opts...), nil
+}
+
+// LoadDescriptorSet reads a binary encoded file descriptor set from the given path
+func LoadDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read descriptor set %q: %w", path, err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	if err = proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("unmarshal descriptor set %q: %w", path, err)
+	}
+	return set, nil
+}
+
+// Mocker returns the mocker used by the server
+func (s *Server) Mocker() *mocker.Mocker {
+	return s.mocker
+}
+
+// Services returns the descriptors of all the services served by the server
+func (s *Server) Services() []protoreflect.ServiceDescriptor {
+	return s.services
+}
+
+// RegisterGRPC registers all the services on the given gRPC server
+func (s *Server) RegisterGRPC(srv *grpc.Server) error {
+	for _, sd := range s.services {
+		srv.RegisterService(s.serviceDesc(sd), s)
+	}
+	return nil
+}
+
+func (s *Server) serviceDesc(sd protoreflect.ServiceDescriptor) *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: string(sd.FullName()),
+		HandlerType: (*any)(nil),
+		Metadata:    sd.ParentFile().Path(),
+	}
+
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		if !md.IsStreamingClient() && !md.IsStreamingServer() {
+			desc.Methods = append(desc.Methods, grpc.MethodDesc{
+				MethodName: string(md.Name()),
+				Handler:    s.unaryHandler(md),
+			})
+			continue
+		}
+		desc.Streams = append(desc.Streams, grpc.StreamDesc{
+			StreamName:    string(md.Name()),
+			Handler:       s.streamHandler(md),
+			ServerStreams: md.IsStreamingServer(),
+			ClientStreams: md.IsStreamingClient(),
+		})
+	}
+	return desc
+}
+
+func (s *Server) fileStubs() stub.MethodFileStubs {
+	if s.stubStore != nil {
+		return s.stubStore.Stubs()
+	}
+	return s.stubs
+}
+
+// result is the resolved return values of a single request
+type result struct {
+	messages  []proto.Message
+	err       error
+	isDefault bool
+	// stubResponse is set for results of file stubs
+	stubResponse *stub.Response
+}
+
+// resolve finds the result of a single request, following the lookup chain of
+// expected call -> file stub -> default call
+func (s *Server) resolve(ctx context.Context, md protoreflect.MethodDescriptor, req proto.Message, args ...any) (*result, error) {
+	method := mocker.MethodName(md)
+
+	expectedCall, err := s.mocker.CallV2(method, args...)
+	if err != nil && !errors.As(err, &mocker.ErrNoMatchingCalls{}) {
+		return nil, err
+	}
+	if err == nil && !expectedCall.IsDefault() {
+		return resultFromReturns(md, expectedCall.Returns())
+	}
+
+	stubRes := dynamicpb.NewMessage(md.Output())
+	stubResponse, err := stub.FindStubResponse(ctx, s.fileStubs(), mocker.MethodName(md), req, stubRes)
+	if err != nil {
+		return nil, err
+	}
+	if stubResponse != nil {
+		return &result{messages: []proto.Message{stubRes}, stubResponse: stubResponse}, nil
+	}
+
+	if expectedCall != nil {
+		res, err := resultFromReturns(md, expectedCall.Returns())
+		if err != nil {
+			return nil, err
+		}
+		res.isDefault = true
+		return res, nil
+	}
+
+	if stubErr := stub.ExplainNoMatch(ctx, s.fileStubs(), mocker.MethodName(md), req); stubErr != nil {
+		return nil, fmt.Errorf("%w. %v", mocker.ErrNoMatchingCalls{Method: method}, stubErr)
+	}
+	return nil, mocker.ErrNoMatchingCalls{Method: method}
+}
+
+func resultFromReturns(md protoreflect.MethodDescriptor, ret []any) (*result, error) {
+	if len(ret) != 2 {
+		return nil, fmt.Errorf("unexpected number of return values. Expected %d return values, got %d", 2, len(ret))
+	}
+
+	res := &result{}
+	res.err, _ = ret[1].(error)
+	if ret[0] == nil {
+		return res, nil
+	}
+
+	if !md.IsStreamingServer() {
+		msg, ok := ret[0].(proto.Message)
+		if !ok {
+			return nil, fmt.Errorf("unexpected return value of type %T, expected proto.Message", ret[0])
+		}
+		res.messages = []proto.Message{msg}
+		return res, nil
+	}
+
+	// Allowing any slice of messages, not only []proto.Message
+	messages := reflect.ValueOf(ret[0])
+	if messages.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("unexpected return value of type %T, expected a slice of proto.Message", ret[0])
+	}
+	for i := 0; i < messages.Len(); i++ {
+		msg, ok := messages.Index(i).Interface().(proto.Message)
+		if !ok {
+			return nil, fmt.Errorf("unexpected return value of type %T, expected a slice of proto.Message", ret[0])
+		}
+		res.messages = append(res.messages, msg)
+	}
+	return res, nil
+}
+
+func (s *Server) unaryHandler(md protoreflect.MethodDescriptor) func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	handle := func(ctx context.Context, req any) (any, error) {
+		ctx = s.mocker.ContextWithMocker(ctx)
+		if err := s.mocker.InjectFault(ctx, mocker.MethodName(md)); err != nil {
+			return nil, err
+		}
+		reqMsg := req.(proto.Message)
+		if err := s.mocker.ValidateRequest(reqMsg); err != nil {
+			return nil, err
+		}
+		res, err := s.resolve(ctx, md, reqMsg, ctx, reqMsg)
+		if err != nil {
+			s.mocker.LogError(err)
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if res.stubResponse != nil {
+			if err = res.stubResponse.Respond(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if res.err != nil {
+			return nil, res.err
+		}
+		if len(res.messages) == 0 {
+			return dynamicpb.NewMessage(md.Output()), nil
+		}
+		return res.messages[0], nil
+	}
+
+	return func(_ any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		req := dynamicpb.NewMessage(md.Input())
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return handle(ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: s, FullMethod: mocker.MethodName(md)}
+		return interceptor(ctx, req, info, handle)
+	}
+}
+
+func (s *Server) streamHandler(md protoreflect.MethodDescriptor) grpc.StreamHandler {
+	return func(_ any, stream grpc.ServerStream) error {
+		stream, err := s.mocker.InjectStreamFault(stream, mocker.MethodName(md))
+		if err != nil {
+			return err
+		}
+		if !md.IsStreamingClient() {
+			return s.handleServerStream(md, stream)
+		}
+		return s.handleClientStream(md, stream)
+	}
+}
+
+func (s *Server) handleServerStream(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+	req := dynamicpb.NewMessage(md.Input())
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	if err := s.mocker.ValidateRequest(req); err != nil {
+		return err
+	}
+
+	res, err := s.resolve(stream.Context(), md, req, req, stream)
+	if err != nil {
+		s.mocker.LogError(err)
+		return status.Error(codes.Internal, err.Error())
+	}
+	return sendResult(stream, res)
+}
+
+func (s *Server) handleClientStream(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+	var defaultResult *result
+	var received []proto.Message
+	found := false
+	for {
+		req := dynamicpb.NewMessage(md.Input())
+		err := stream.RecvMsg(req)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			err = fmt.Errorf("recv: %w", err)
+			s.mocker.LogError(err)
+			return status.Error(codes.Internal, err.Error())
+		}
+		if err := s.mocker.ValidateRequest(req); err != nil {
+			return err
+		}
+		received = append(received, req)
+
+		res, err := s.resolve(stream.Context(), md, req, req, stream)
+		if errors.As(err, &mocker.ErrNoMatchingCalls{}) {
+			// A later message or the whole stream may still match, unmatched streams are handled once closed
+			continue
+		}
+		if err != nil {
+			s.mocker.LogError(err)
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if md.IsStreamingServer() {
+			if err = sendResult(stream, res); err != nil {
+				return err
+			}
+			found = true
+			continue
+		}
+
+		// A single response is sent for the whole stream, so a default is only used if nothing else matched
+		if res.isDefault {
+			defaultResult = res
+			continue
+		}
+		return sendResult(stream, res)
+	}
+
+	stubRes := dynamicpb.NewMessage(md.Output())
+	stubResponse, err := stub.FindStreamStubResponse(stream.Context(), s.fileStubs(), mocker.MethodName(md), received, stubRes)
+	if err != nil {
+		s.mocker.LogError(err)
+		return status.Error(codes.Internal, err.Error())
+	}
+	if stubResponse != nil {
+		return sendResult(stream, &result{messages: []proto.Message{stubRes}, stubResponse: stubResponse})
+	}
+	if defaultResult != nil {
+		return sendResult(stream, defaultResult)
+	}
+	if found {
+		return nil
+	}
+
+	err = mocker.ErrNoMatchingCalls{Method: mocker.MethodName(md)}
+	if stubErr := stub.ExplainStreamNoMatch(stream.Context(), s.fileStubs(), mocker.MethodName(md), received); stubErr != nil {
+		err = fmt.Errorf("%w. %v", err, stubErr)
+	}
+	s.mocker.LogError(err)
+	return status.Error(codes.NotFound, err.Error())
+}
+
+func sendResult(stream grpc.ServerStream, res *result) error {
+	if res.stubResponse != nil {
+		return res.stubResponse.RespondStream(stream, res.messages[0])
+	}
+	if res.err != nil {
+		return res.err
+	}
+	for _, msg := range res.messages {
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
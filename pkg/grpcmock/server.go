@@ -0,0 +1,89 @@
+package grpcmock
+
+import (
+	"testing"
+
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc"
+)
+
+// Server hosts several generated mock servers on a single gRPC server, all sharing a single mocker.
+// Sharing the mocker gives a single calls journal across all the hosted services (so cross-service call ordering can be
+// asserted), a single ResetAll and a single AssertExpectations.
+// Method keys of the shared mocker are the full gRPC method names (e.g. "/pkg.ExampleService/ExampleMethod"), so
+// services with the same method names don't collide.
+type Server struct {
+	t       *testing.T
+	mocker  *mocker.Mocker
+	harness *mocker.Harness
+}
+
+// NewServer creates a new Server, served on an in-process gRPC server for the duration of the test
+func NewServer(t *testing.T, opts ...mocker.HarnessOption) *Server {
+	t.Helper()
+
+	m := mocker.NewMocker()
+	m.SetT(t)
+	return &Server{
+		t:       t,
+		mocker:  m,
+		harness: mocker.NewHarness(t, opts...),
+	}
+}
+
+// Add creates a new mock server using the given generated New<Service>MockServerWithMocker constructor, backed by the
+// shared mocker of srv, and registers it on srv.
+//
+//	exampleServer := grpcmock.Add(srv, NewExampleServiceMockServerWithMocker)
+func Add[M mocker.GRPCRegistrar](srv *Server, newMockServer func(m *mocker.Mocker) M) M {
+	srv.t.Helper()
+
+	mockServer := newMockServer(srv.mocker)
+	srv.Register(mockServer)
+	return mockServer
+}
+
+// Register registers mock servers on the server. The mock servers should be created with the shared mocker returned
+// from Mocker. It must be called before the server is started.
+func (s *Server) Register(mockServers ...mocker.GRPCRegistrar) {
+	s.t.Helper()
+	s.harness.Register(mockServers...)
+}
+
+// Mocker returns the mocker shared by all the mock servers hosted by the server
+func (s *Server) Mocker() *mocker.Mocker {
+	return s.mocker
+}
+
+// GRPCServer returns the underlying gRPC server
+func (s *Server) GRPCServer() *grpc.Server {
+	return s.harness.Server()
+}
+
+// Conn starts the server if needed and returns a client connection to it
+func (s *Server) Conn() *grpc.ClientConn {
+	s.t.Helper()
+	return s.harness.Conn()
+}
+
+// Addr starts the server if needed and returns the address it listens on
+func (s *Server) Addr() string {
+	s.t.Helper()
+	return s.harness.Addr()
+}
+
+// Calls returns the journal of all the calls received by all the hosted services, in the order they were received
+func (s *Server) Calls() []mocker.RecordedCall {
+	return s.mocker.Calls()
+}
+
+// ResetAll deletes all the expected calls, default calls and the calls journal of all the hosted services
+func (s *Server) ResetAll() {
+	s.mocker.ResetAll()
+}
+
+// AssertExpectations reports a test error for every expected call of all the hosted services which was never called
+func (s *Server) AssertExpectations() bool {
+	s.t.Helper()
+	return s.mocker.AssertExpectations(s.t)
+}
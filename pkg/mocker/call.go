@@ -17,6 +17,11 @@ type SingleExpectedCall struct {
 	id            string
 	actualCalls   int
 	isDefault     bool
+	// scenario and scenarioState gate the call on the state of a scenario, which transitionTo transitions once the
+	// call matches (see RegisteredCall.InScenarioState). They're guarded by the lock of the mocker.
+	scenario      string
+	scenarioState string
+	transitionTo  string
 	mu            *sync.RWMutex
 }
 
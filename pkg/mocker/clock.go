@@ -0,0 +1,177 @@
+package mocker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// Clock tells the time to the mocker: the rate limits, the injected latency, the timestamps of the call journal, and
+// the delays and `now` functions of the file stubs use it. It's the wall clock by default (see Mocker.SetClock), and
+// can be replaced by a FakeClock to make time dependent tests deterministic, without sleeping.
+type Clock interface {
+	Now() time.Time
+	// NewTimer creates a timer sending the time on its channel once d elapsed
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer created by a Clock
+type Timer interface {
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if it already fired or was stopped.
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// FakeClock is a Clock which only moves when advanced. Its timers fire once it's advanced past their deadline.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// changed is broadcast when timers are created, so BlockUntilTimers can wait for them
+	changed *sync.Cond
+}
+
+// NewFakeClock creates a fake clock set to now
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.changed.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers whose deadline is reached
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.c <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// BlockUntilTimers blocks until n timers of the clock are pending, e.g. until a call is waiting its delay, so that
+// advancing the clock fires them
+func (c *FakeClock) BlockUntilTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetClock sets the clock of the mocker. nil sets the wall clock back.
+func (m *Mocker) SetClock(clock Clock) {
+	if clock == nil {
+		clock = realClock{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clock = clock
+}
+
+// Clock returns the clock of the mocker
+func (m *Mocker) Clock() Clock {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.clock
+}
+
+// ClockFromContext returns the clock of the mocker carried by ctx (see Mocker.ContextWithMocker), or the wall clock if
+// there's none
+func ClockFromContext(ctx context.Context) Clock {
+	if m := FromContext(ctx); m != nil {
+		return m.Clock()
+	}
+	return realClock{}
+}
+
+// Wait waits d on the clock carried by ctx (see ClockFromContext), or until ctx is done, in which case it returns the
+// status error of ctx
+func Wait(ctx context.Context, d time.Duration) error {
+	return wait(ctx, ClockFromContext(ctx), d)
+}
+
+func wait(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	case <-timer.C():
+		return nil
+	}
+}
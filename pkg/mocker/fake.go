@@ -0,0 +1,300 @@
+package mocker
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// DefaultFakeDepth is the default depth of the nested messages populated by a Faker
+	DefaultFakeDepth = 3
+	// DefaultFakeListLen is the default maximal length of the repeated fields, maps and server streams populated by a
+	// Faker
+	DefaultFakeListLen = 3
+)
+
+const fakeLetters = "abcdefghijklmnopqrstuvwxyz"
+
+// anyFullName is the name of the Any messages, which are left unset as they can't hold a random type
+const anyFullName = "google.protobuf.Any"
+
+var (
+	// fakeMinTime and fakeMaxTime bound the fake timestamps
+	fakeMinTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	fakeMaxTime = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+type fakeConfig struct {
+	seed    int64
+	depth   int
+	listLen int
+}
+
+// FakeOption configures a Faker
+type FakeOption func(cfg *fakeConfig)
+
+// WithFakeSeed seeds the random data of the Faker, so it populates the same messages on every run
+func WithFakeSeed(seed int64) FakeOption {
+	return func(cfg *fakeConfig) {
+		cfg.seed = seed
+	}
+}
+
+// WithFakeDepth sets the depth of the nested messages populated by the Faker (DefaultFakeDepth by default). The
+// message fields deeper than that are left unset, which ends recursive messages.
+func WithFakeDepth(depth int) FakeOption {
+	return func(cfg *fakeConfig) {
+		cfg.depth = depth
+	}
+}
+
+// WithFakeListLen sets the maximal length of the repeated fields, maps and server streams populated by the Faker
+// (DefaultFakeListLen by default). They always get at least one element.
+func WithFakeListLen(n int) FakeOption {
+	return func(cfg *fakeConfig) {
+		cfg.listLen = n
+	}
+}
+
+// Faker populates messages with random but valid data, following their descriptors:
+//   - Enums are set to one of their declared values, preferring the non-zero ones.
+//   - Exactly one field of every oneof is set, and proto3 optional fields are always set.
+//   - Repeated fields and maps get between 1 and the list length elements.
+//   - Timestamps are between the years 2000 and 2030, durations are positive and shorter than a day. Any fields are
+//     left unset, as they can't hold a random type.
+//   - Floating point numbers are finite, strings are lowercase words and bytes are random.
+//
+// A Faker is safe for concurrent use.
+type Faker struct {
+	depth   int
+	listLen int
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewFaker creates a Faker, seeded by the current time unless WithFakeSeed is given
+func NewFaker(opts ...FakeOption) *Faker {
+	cfg := fakeConfig{
+		seed:    time.Now().UnixNano(),
+		depth:   DefaultFakeDepth,
+		listLen: DefaultFakeListLen,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.listLen < 1 {
+		cfg.listLen = 1
+	}
+	return &Faker{
+		depth:   cfg.depth,
+		listLen: cfg.listLen,
+		rnd:     rand.New(rand.NewSource(cfg.seed)),
+	}
+}
+
+// Fake returns a new message of type T populated with random but valid data (see Faker), e.g.
+//
+//	book := mocker.Fake[*pb.Book](mocker.WithFakeSeed(1))
+func Fake[T proto.Message](opts ...FakeOption) T {
+	var zero T
+	msg := zero.ProtoReflect().Type().New().Interface().(T)
+	NewFaker(opts...).Fill(msg)
+	return msg
+}
+
+// Fill clears msg and populates it with random but valid data
+func (f *Faker) Fill(msg proto.Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	proto.Reset(msg)
+	f.fillMessage(msg.ProtoReflect(), 0)
+}
+
+// len returns a random length of a list, between 1 and the list length
+func (f *Faker) len() int {
+	return 1 + f.rnd.Intn(f.listLen)
+}
+
+func (f *Faker) fillMessage(m protoreflect.Message, depth int) {
+	md := m.Descriptor()
+	switch md.FullName() {
+	case anyFullName:
+		return
+	case "google.protobuf.Timestamp":
+		seconds := fakeMinTime.Unix() + f.rnd.Int63n(fakeMaxTime.Unix()-fakeMinTime.Unix())
+		m.Set(md.Fields().ByName("seconds"), protoreflect.ValueOfInt64(seconds))
+		m.Set(md.Fields().ByName("nanos"), protoreflect.ValueOfInt32(f.rnd.Int31n(int32(time.Second))))
+		return
+	case "google.protobuf.Duration":
+		m.Set(md.Fields().ByName("seconds"), protoreflect.ValueOfInt64(f.rnd.Int63n(int64(24*time.Hour/time.Second))))
+		m.Set(md.Fields().ByName("nanos"), protoreflect.ValueOfInt32(f.rnd.Int31n(int32(time.Second))))
+		return
+	}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			continue
+		}
+		f.fillField(m, fd, depth)
+	}
+
+	oneofs := md.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		oneof := oneofs.Get(i)
+		if oneof.IsSynthetic() {
+			continue
+		}
+		// Another field is chosen if there's one, rather than a field left unset
+		var candidates []protoreflect.FieldDescriptor
+		for j := 0; j < oneof.Fields().Len(); j++ {
+			fd := oneof.Fields().Get(j)
+			if !f.leftUnset(fd, depth) {
+				candidates = append(candidates, fd)
+			}
+		}
+		if len(candidates) > 0 {
+			f.fillField(m, candidates[f.rnd.Intn(len(candidates))], depth)
+		}
+	}
+}
+
+// leftUnset returns whether the field of a message at the given depth is left unset, which are the message fields
+// beyond the depth and the Any fields
+func (f *Faker) leftUnset(fd protoreflect.FieldDescriptor, depth int) bool {
+	md := fd.Message()
+	if fd.IsMap() {
+		md = fd.MapValue().Message()
+	}
+	return md != nil && (depth+1 >= f.depth || md.FullName() == anyFullName)
+}
+
+func (f *Faker) fillField(m protoreflect.Message, fd protoreflect.FieldDescriptor, depth int) {
+	if f.leftUnset(fd, depth) {
+		return
+	}
+	switch {
+	case fd.IsMap():
+		mp := m.Mutable(fd).Map()
+		for i, n := 0, f.len(); i < n; i++ {
+			key := f.scalar(fd.MapKey()).MapKey()
+			if fd.MapValue().Message() != nil {
+				f.fillMessage(mp.Mutable(key).Message(), depth+1)
+				continue
+			}
+			mp.Set(key, f.scalar(fd.MapValue()))
+		}
+	case fd.IsList():
+		list := m.Mutable(fd).List()
+		for i, n := 0, f.len(); i < n; i++ {
+			if fd.Message() != nil {
+				f.fillMessage(list.AppendMutable().Message(), depth+1)
+				continue
+			}
+			list.Append(f.scalar(fd))
+		}
+	case fd.Message() != nil:
+		f.fillMessage(m.Mutable(fd).Message(), depth+1)
+	default:
+		m.Set(fd, f.scalar(fd))
+	}
+}
+
+// scalar returns a random value of a field which isn't a message
+func (f *Faker) scalar(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(f.rnd.Intn(2) == 1)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		if values.Len() == 1 {
+			return protoreflect.ValueOfEnum(values.Get(0).Number())
+		}
+		// Skipping the first value, which is the zero value of proto3 enums
+		return protoreflect.ValueOfEnum(values.Get(1 + f.rnd.Intn(values.Len()-1)).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(f.rnd.Int31n(1000))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(f.rnd.Int63n(1000))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(f.rnd.Int31n(1000)))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(f.rnd.Int63n(1000)))
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(f.rnd.Float32() * 1000)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(f.rnd.Float64() * 1000)
+	case protoreflect.StringKind:
+		word := make([]byte, 4+f.rnd.Intn(8))
+		for i := range word {
+			word[i] = fakeLetters[f.rnd.Intn(len(fakeLetters))]
+		}
+		return protoreflect.ValueOfString(string(word))
+	case protoreflect.BytesKind:
+		b := make([]byte, 4+f.rnd.Intn(12))
+		f.rnd.Read(b)
+		return protoreflect.ValueOfBytes(b)
+	}
+	return fd.Default()
+}
+
+// fakeReturns returns the return values of a call to the given method responding with fake messages: a message for
+// unary and client streaming methods, and a slice of messages for server streaming methods.
+func (f *Faker) fakeReturns(md protoreflect.MethodDescriptor) []any {
+	newMessage := func() proto.Message {
+		msg := NewMessage(md.Output())
+		f.Fill(msg)
+		return msg
+	}
+
+	if !md.IsStreamingServer() {
+		return []any{newMessage(), nil}
+	}
+	// A typed slice, as the generated mock servers expect
+	f.mu.Lock()
+	n := f.len()
+	f.mu.Unlock()
+	messages := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(NewMessage(md.Output()))), 0, n)
+	for i := 0; i < n; i++ {
+		messages = reflect.Append(messages, reflect.ValueOf(newMessage()))
+	}
+	return []any{messages.Interface(), nil}
+}
+
+// SetDefaultFakeCall sets a default call for the provided method that will return new messages populated by faker
+// (see Faker). The method must be registered to the mocker.
+func (m *Mocker) SetDefaultFakeCall(method string, faker *Faker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	md, ok := m.methods[method]
+	call := newSingleExpectedCallWithFunc([]any{}, func() []any {
+		if !ok {
+			return []any{nil, status.Errorf(codes.Internal, "method %v isn't registered to the mocker, so it can't be faked", method)}
+		}
+		return faker.fakeReturns(md)
+	})
+	call.setDefault()
+	m.defaultCalls[method] = &call
+}
+
+// SetFakeUnmatched makes the calls of registered methods which match no expected call nor default return respond with
+// new messages populated by faker (see Faker), as if a default fake call was set for every method. In the generated
+// mock servers the file stubs and cassettes still take precedence, while the fallback and record mode are never
+// reached. A nil faker disables it. ResetAll keeps it.
+func (m *Mocker) SetFakeUnmatched(faker *Faker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fakeUnmatched = faker
+}
@@ -0,0 +1,329 @@
+package mocker
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// ConnectionResetMessage is the message of the Unavailable status of calls failed by a connection reset fault, which is
+// what gRPC clients observe when the connection of a call is reset
+const ConnectionResetMessage = "connection reset by peer"
+
+// FaultProfile injects faults into the calls of a mock server, for chaos testing: error statuses, latency, truncated
+// server streams, connection resets and rate limits. It's applied globally (see Mocker.SetFaultProfile) or per method (see
+// Mocker.SetMethodFaultProfile).
+// The faults are drawn from a random source seeded with Seed, so the same calls made in the same order get the same
+// faults.
+// Calls failed by a fault never reach the mock, so they're not recorded in its call journal.
+type FaultProfile struct {
+	// Errors are statuses returned instead of calling the mock, each with its own probability
+	Errors []StatusFault `yaml:"errors"`
+	// Latency, if set, is waited before calling the mock
+	Latency *Latency `yaml:"latency"`
+	// TruncateProbability is the probability of each message of a server stream to be dropped along with the rest of
+	// the stream: the client receives fewer messages, and then the status the mock ends the stream with
+	TruncateProbability float64 `yaml:"truncate_probability"`
+	// ResetProbability is the probability of a call to fail as if its connection was reset (see ConnectionResetMessage).
+	// Server streams may also be reset before each message they send, with the same probability.
+	ResetProbability float64 `yaml:"reset_probability"`
+	Seed             int64   `yaml:"seed"`
+	// RateLimit, if set, fails the calls exceeding it with a ResourceExhausted status (see RateLimit). With a global
+	// profile, it limits all the calls of the methods without their own profile together.
+	RateLimit *RateLimit `yaml:"rate_limit"`
+}
+
+// StatusFault is an error status returned by a fraction of the calls
+type StatusFault struct {
+	Code    codes.Code
+	Message string
+	// Probability is the fraction of the calls failing with the status, between 0 and 1
+	Probability float64
+}
+
+// UnmarshalYAML reads the code by its name, e.g. UNAVAILABLE
+func (f *StatusFault) UnmarshalYAML(node *yaml.Node) error {
+	var fault struct {
+		Code        string  `yaml:"code"`
+		Message     string  `yaml:"message"`
+		Probability float64 `yaml:"probability"`
+	}
+	if err := node.Decode(&fault); err != nil {
+		return err
+	}
+	if err := f.Code.UnmarshalJSON([]byte(strconv.Quote(fault.Code))); err != nil {
+		return fmt.Errorf("line %d: invalid status code %q", node.Line, fault.Code)
+	}
+	f.Message = fault.Message
+	f.Probability = fault.Probability
+	return nil
+}
+
+// Latency is a distribution of delays. Delays are uniformly distributed between Min and Max (a fixed delay when Max
+// isn't greater than Min), unless StdDev is set: then they're normally distributed around Mean, and limited to Min and
+// Max (when it's set).
+type Latency struct {
+	Min    time.Duration `yaml:"min"`
+	Max    time.Duration `yaml:"max"`
+	Mean   time.Duration `yaml:"mean"`
+	StdDev time.Duration `yaml:"stddev"`
+}
+
+// Validate returns an error if the profile can't be applied
+func (p *FaultProfile) Validate() error {
+	total := p.ResetProbability
+	for _, fault := range p.Errors {
+		if fault.Code == codes.OK {
+			return fmt.Errorf("error fault must have an error status code")
+		}
+		total += fault.Probability
+	}
+	if p.TruncateProbability < 0 || p.TruncateProbability > 1 {
+		return fmt.Errorf("truncate probability must be between 0 and 1, got %v", p.TruncateProbability)
+	}
+	if p.ResetProbability < 0 || total > 1 {
+		return fmt.Errorf("the probabilities of the errors and of a connection reset must add up to between 0 and 1, got %v", total)
+	}
+	for _, fault := range p.Errors {
+		if fault.Probability < 0 {
+			return fmt.Errorf("error probability can't be negative, got %v", fault.Probability)
+		}
+	}
+	if p.Latency != nil && (p.Latency.Min < 0 || p.Latency.Max < 0 || p.Latency.Mean < 0 || p.Latency.StdDev < 0) {
+		return fmt.Errorf("latency can't be negative")
+	}
+	if p.RateLimit != nil {
+		return p.RateLimit.Validate()
+	}
+	return nil
+}
+
+// faultInjector draws the faults of a profile
+type faultInjector struct {
+	profile FaultProfile
+	limiter *rateLimiter
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newFaultInjector(profile *FaultProfile) *faultInjector {
+	injector := &faultInjector{profile: *profile, rand: rand.New(rand.NewSource(profile.Seed))}
+	if profile.RateLimit != nil {
+		injector.limiter = newRateLimiter(profile.RateLimit)
+	}
+	return injector
+}
+
+func (f *faultInjector) float64() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rand.Float64()
+}
+
+// latency returns the delay to inject into a call
+func (f *faultInjector) latency() time.Duration {
+	l := f.profile.Latency
+	if l == nil {
+		return 0
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if l.StdDev > 0 {
+		delay := l.Mean + time.Duration(f.rand.NormFloat64()*float64(l.StdDev))
+		if delay < l.Min {
+			delay = l.Min
+		}
+		if l.Max > 0 && delay > l.Max {
+			delay = l.Max
+		}
+		return delay
+	}
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	return l.Min + time.Duration(f.rand.Int63n(int64(l.Max-l.Min)+1))
+}
+
+// callError returns the error status of a call, if it's failed by a fault
+func (f *faultInjector) callError() error {
+	if len(f.profile.Errors) == 0 && f.profile.ResetProbability == 0 {
+		return nil
+	}
+	draw := f.float64()
+	if draw < f.profile.ResetProbability {
+		return status.Error(codes.Unavailable, ConnectionResetMessage)
+	}
+	threshold := f.profile.ResetProbability
+	for _, fault := range f.profile.Errors {
+		threshold += fault.Probability
+		if draw < threshold {
+			msg := fault.Message
+			if msg == "" {
+				msg = "injected fault"
+			}
+			return status.Error(fault.Code, msg)
+		}
+	}
+	return nil
+}
+
+// SetFaultProfile sets the fault profile of all the methods which don't have their own (see SetMethodFaultProfile).
+// nil removes it. Fault profiles are kept by ResetAll.
+func (m *Mocker) SetFaultProfile(profile *FaultProfile) error {
+	return m.SetMethodFaultProfile("", profile)
+}
+
+// SetMethodFaultProfile sets the fault profile of a method, by its full gRPC method name. nil removes it.
+func (m *Mocker) SetMethodFaultProfile(method string, profile *FaultProfile) error {
+	var injector *faultInjector
+	if profile != nil {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("invalid fault profile: %w", err)
+		}
+		injector = newFaultInjector(profile)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if injector == nil {
+		delete(m.faults, method)
+	} else {
+		m.faults[method] = injector
+	}
+	return nil
+}
+
+func (m *Mocker) faultInjector(method string) *faultInjector {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if injector, ok := m.faults[method]; ok {
+		return injector
+	}
+	return m.faults[""]
+}
+
+// InjectFault applies the fault profile of the method (see SetMethodFaultProfile) to a call: it applies the rate limit,
+// waits the injected latency, and returns the error status of the call if it's failed by a fault. It's called by the
+// mock servers before handling each call.
+func (m *Mocker) InjectFault(ctx context.Context, method string) error {
+	return m.injectFault(ctx, m.faultInjector(method))
+}
+
+func (m *Mocker) injectFault(ctx context.Context, injector *faultInjector) error {
+	if injector == nil {
+		return nil
+	}
+
+	clock := m.Clock()
+	if injector.limiter != nil {
+		if err := injector.limiter.take(ctx, clock.Now()); err != nil {
+			return err
+		}
+	}
+	if err := wait(ctx, clock, injector.latency()); err != nil {
+		return err
+	}
+	return injector.callError()
+}
+
+// InjectStreamFault is like InjectFault, for streaming calls. It returns the stream to handle the call with, which
+// truncates and resets the server stream according to the fault profile, and whose context carries the mocker (see
+// ContextWithMocker).
+func (m *Mocker) InjectStreamFault(stream grpc.ServerStream, method string) (grpc.ServerStream, error) {
+	stream = &contextStream{ServerStream: stream, ctx: m.ContextWithMocker(stream.Context())}
+	injector := m.faultInjector(method)
+	if err := m.injectFault(stream.Context(), injector); err != nil {
+		return nil, err
+	}
+	if injector == nil || (injector.profile.TruncateProbability == 0 && injector.profile.ResetProbability == 0) {
+		return stream, nil
+	}
+	return &faultyStream{ServerStream: stream, injector: injector}, nil
+}
+
+// faultyStream is a server stream truncated and reset by a fault profile
+type faultyStream struct {
+	grpc.ServerStream
+	injector  *faultInjector
+	truncated bool
+}
+
+func (s *faultyStream) SendMsg(m any) error {
+	if s.truncated {
+		return nil
+	}
+	draw := s.injector.float64()
+	if draw < s.injector.profile.ResetProbability {
+		return status.Error(codes.Unavailable, ConnectionResetMessage)
+	}
+	if draw < s.injector.profile.ResetProbability+s.injector.profile.TruncateProbability {
+		s.truncated = true
+		return nil
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// FaultConfig is the fault profiles of a mocker, as configured in a YAML or JSON file. For example:
+//
+//	global:
+//	  latency: {min: 10ms, max: 50ms}
+//	methods:
+//	  /accounts.v1.AccountService/GetAccount:
+//	    errors:
+//	      - {code: UNAVAILABLE, probability: 0.1}
+//	      - {code: DEADLINE_EXCEEDED, message: too slow, probability: 0.05}
+//	    latency: {mean: 200ms, stddev: 50ms}
+//	    truncate_probability: 0.2
+//	    reset_probability: 0.01
+//	    seed: 42
+//	  /accounts.v1.AccountService/ListAccounts:
+//	    rate_limit: {requests: 10, per: 1s, burst: 20, metadata_key: x-api-key}
+type FaultConfig struct {
+	// Global is the profile of the methods without their own profile
+	Global *FaultProfile `yaml:"global"`
+	// Methods are the profiles of methods, by their full gRPC method name
+	Methods map[string]*FaultProfile `yaml:"methods"`
+}
+
+// LoadFaultConfig reads a fault config file
+func LoadFaultConfig(path string) (*FaultConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open fault config %q: %w", path, err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	config := &FaultConfig{}
+	if err = decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("parse fault config %q: %w", path, err)
+	}
+	return config, nil
+}
+
+// Apply sets the fault profiles of the config on the mocker
+func (c *FaultConfig) Apply(m *Mocker) error {
+	if c.Global != nil {
+		if err := m.SetFaultProfile(c.Global); err != nil {
+			return fmt.Errorf("global: %w", err)
+		}
+	}
+	for method, profile := range c.Methods {
+		if err := m.SetMethodFaultProfile(method, profile); err != nil {
+			return fmt.Errorf("method %v: %w", method, err)
+		}
+	}
+	return nil
+}
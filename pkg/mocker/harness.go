@@ -0,0 +1,198 @@
+package mocker
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufconnSize = 1024 * 1024
+
+// GRPCRegistrar is implemented by every generated mock server, allowing it to be registered on a gRPC server.
+type GRPCRegistrar interface {
+	RegisterGRPC(srv *grpc.Server) error
+}
+
+type harnessConfig struct {
+	serverOptions      []grpc.ServerOption
+	dialOptions        []grpc.DialOption
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+	serverCreds        credentials.TransportCredentials
+	clientCreds        credentials.TransportCredentials
+	tcpAddr            string
+}
+
+// HarnessOption configures a Harness
+type HarnessOption func(cfg *harnessConfig)
+
+// WithUnaryInterceptors adds unary interceptors to the harness gRPC server
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) HarnessOption {
+	return func(cfg *harnessConfig) {
+		cfg.unaryInterceptors = append(cfg.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors adds stream interceptors to the harness gRPC server
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) HarnessOption {
+	return func(cfg *harnessConfig) {
+		cfg.streamInterceptors = append(cfg.streamInterceptors, interceptors...)
+	}
+}
+
+// WithServerOptions adds raw gRPC server options to the harness gRPC server
+func WithServerOptions(opts ...grpc.ServerOption) HarnessOption {
+	return func(cfg *harnessConfig) {
+		cfg.serverOptions = append(cfg.serverOptions, opts...)
+	}
+}
+
+// WithDialOptions adds dial options used when creating the harness client connection
+func WithDialOptions(opts ...grpc.DialOption) HarnessOption {
+	return func(cfg *harnessConfig) {
+		cfg.dialOptions = append(cfg.dialOptions, opts...)
+	}
+}
+
+// WithTLS serves the harness with the given server credentials and dials it with the given client credentials.
+// Without this option the harness is served and dialed insecurely.
+func WithTLS(serverCreds, clientCreds credentials.TransportCredentials) HarnessOption {
+	return func(cfg *harnessConfig) {
+		cfg.serverCreds = serverCreds
+		cfg.clientCreds = clientCreds
+	}
+}
+
+// WithTCPListener serves the harness on a real TCP listener on the given address (for example "127.0.0.1:0")
+// instead of an in-memory bufconn listener. Useful when the code under test dials an address by itself.
+func WithTCPListener(addr string) HarnessOption {
+	return func(cfg *harnessConfig) {
+		cfg.tcpAddr = addr
+	}
+}
+
+// Harness hosts one or more mock servers on a single gRPC server for tests, and provides a ready client connection
+// to it. The harness listens once created, and serves on the first call to Conn or Addr, so the services must be
+// registered before. It's stopped when the test ends.
+type Harness struct {
+	t   *testing.T
+	cfg harnessConfig
+	srv *grpc.Server
+	lis net.Listener
+
+	conn      *grpc.ClientConn
+	startOnce sync.Once
+}
+
+// NewHarness creates a new Harness. The services to host must be registered using Register before the harness is started.
+// Failing to listen or to create the client connection fails the test, so NewHarness must be called from the test
+// goroutine (see testing.T.FailNow), while Conn and Addr can be called from any goroutine.
+func NewHarness(t *testing.T, opts ...HarnessOption) *Harness {
+	t.Helper()
+
+	var cfg harnessConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	serverOptions := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(cfg.unaryInterceptors...),
+		grpc.ChainStreamInterceptor(cfg.streamInterceptors...),
+	}, cfg.serverOptions...)
+	if cfg.serverCreds != nil {
+		serverOptions = append(serverOptions, grpc.Creds(cfg.serverCreds))
+	}
+	h := &Harness{
+		t:   t,
+		cfg: cfg,
+		srv: grpc.NewServer(serverOptions...),
+	}
+
+	var dialer func(ctx context.Context, addr string) (net.Conn, error)
+	target := "passthrough:///bufconn"
+	if cfg.tcpAddr != "" {
+		lis, err := net.Listen("tcp", cfg.tcpAddr)
+		if err != nil {
+			t.Fatalf("grpcmock: listen on %q: %v", cfg.tcpAddr, err)
+		}
+		h.lis = lis
+		target = "passthrough:///" + lis.Addr().String()
+	} else {
+		lis := bufconn.Listen(bufconnSize)
+		h.lis = lis
+		dialer = func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}
+	}
+
+	clientCreds := insecure.NewCredentials()
+	if cfg.clientCreds != nil {
+		clientCreds = cfg.clientCreds
+	}
+	dialOptions := append([]grpc.DialOption{grpc.WithTransportCredentials(clientCreds)}, cfg.dialOptions...)
+	if dialer != nil {
+		dialOptions = append(dialOptions, grpc.WithContextDialer(dialer))
+	}
+
+	// The client connects once used, so it can be created before the harness serves
+	conn, err := grpc.NewClient(target, dialOptions...)
+	if err != nil {
+		_ = h.lis.Close()
+		t.Fatalf("grpcmock: dial harness: %v", err)
+	}
+	h.conn = conn
+
+	t.Cleanup(func() {
+		_ = h.conn.Close()
+		h.srv.Stop()
+		// The listener isn't closed by Stop if the harness never served
+		_ = h.lis.Close()
+	})
+	return h
+}
+
+// Register registers the given mock servers on the harness gRPC server. It must be called before the harness is started.
+func (h *Harness) Register(services ...GRPCRegistrar) {
+	h.t.Helper()
+
+	for _, svc := range services {
+		if err := svc.RegisterGRPC(h.srv); err != nil {
+			h.t.Fatalf("grpcmock: register service: %v", err)
+		}
+	}
+}
+
+// Server returns the underlying gRPC server, for registering additional non-mock services on it
+func (h *Harness) Server() *grpc.Server {
+	return h.srv
+}
+
+// Conn starts the harness if needed and returns a client connection to it
+func (h *Harness) Conn() *grpc.ClientConn {
+	h.start()
+	return h.conn
+}
+
+// Addr starts the harness if needed and returns the address it listens on.
+// It is only dialable from outside the harness when WithTCPListener is used.
+func (h *Harness) Addr() string {
+	h.start()
+	return h.lis.Addr().String()
+}
+
+// start serves the registered services. It can't fail, as the harness listens once created, so it can be called from
+// any goroutine.
+func (h *Harness) start() {
+	h.startOnce.Do(func() {
+		go func() {
+			// Serve returns once the server is stopped at the end of the test
+			_ = h.srv.Serve(h.lis)
+		}()
+	})
+}
@@ -3,6 +3,8 @@ package mocker
 import (
 	"reflect"
 
+	"github.com/nsf/jsondiff"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
@@ -10,6 +12,16 @@ import (
 func Any() Matcher             { return &anyMatcher{} }
 func Eq(x interface{}) Matcher { return &eqMatcher{x} }
 
+// JSONSubset returns a matcher matching proto messages whose JSON form is equal to the given JSON, or is a superset
+// of it (so fields which are not part of the given JSON are not compared).
+func JSONSubset(expected []byte) Matcher { return &jsonSubsetMatcher{expected} }
+
+// IsAny returns whether x is a matcher created by Any()
+func IsAny(x any) bool {
+	_, ok := x.(*anyMatcher)
+	return ok
+}
+
 type anyMatcher struct{}
 
 func (a *anyMatcher) Matches(x interface{}) bool {
@@ -55,3 +67,23 @@ func protoMatches(a, b interface{}) (isMatching, isProto bool) {
 
 	return proto.Equal(aProto, bProto), true
 }
+
+type jsonSubsetMatcher struct {
+	expected []byte
+}
+
+func (j *jsonSubsetMatcher) Matches(x interface{}) bool {
+	msg, ok := x.(proto.Message)
+	if !ok {
+		return false
+	}
+
+	gotJSON, err := protojson.Marshal(msg)
+	if err != nil {
+		return false
+	}
+
+	o := jsondiff.DefaultJSONOptions()
+	compareRes, _ := jsondiff.Compare(gotJSON, j.expected, &o)
+	return compareRes == jsondiff.FullMatch || compareRes == jsondiff.SupersetMatch
+}
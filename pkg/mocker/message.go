@@ -0,0 +1,31 @@
+package mocker
+
+import (
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NewMessage creates a message of the given descriptor, of its generated Go type if it's linked into the binary (so it
+// can be returned by the generated mock servers), or a dynamic message otherwise
+func NewMessage(md protoreflect.MessageDescriptor) proto.Message {
+	if mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName()); err == nil {
+		return mt.New().Interface()
+	}
+	return dynamicpb.NewMessage(md)
+}
+
+// MessageToStruct converts a message to a Struct of its JSON form, e.g. to carry it in the admin API.
+// The numbers of a Struct are doubles, so integers above 2^53 lose precision in it. 64-bit integer fields are
+// carried as strings in the JSON form, so they keep theirs, but so must the 64-bit integers written by hand in a Struct.
+func MessageToStruct(msg proto.Message) (*structpb.Struct, error) {
+	msgJSON, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	res := &structpb.Struct{}
+	return res, protojson.Unmarshal(msgJSON, res)
+}
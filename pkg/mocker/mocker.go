@@ -1,9 +1,15 @@
 package mocker
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 type ErrNoMatchingCalls struct {
@@ -11,8 +17,29 @@ type ErrNoMatchingCalls struct {
 }
 
 func (e ErrNoMatchingCalls) Error() string {
+	name := MethodShortName(e.Method)
 	return fmt.Sprintf("no matching expected call nor default return for method %v with given arguments. "+
-		"Use Configure().%v() to configure an expected call or default return value", e.Method, e.Method)
+		"Use Configure().%v() to configure an expected call or default return value", name, name)
+}
+
+// MethodName returns the full gRPC method name of the given method descriptor (e.g. "/pkg.ExampleService/ExampleMethod"),
+// which is the key used by the Mocker for that method.
+func MethodName(md protoreflect.MethodDescriptor) string {
+	return "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+}
+
+// MethodShortName returns the bare method name of a full gRPC method name (e.g. "ExampleMethod" for
+// "/pkg.ExampleService/ExampleMethod"). Method names which are not fully qualified are returned as is.
+func MethodShortName(method string) string {
+	return method[strings.LastIndex(method, "/")+1:]
+}
+
+// RecordedCall is a single call received by the Mocker, as kept in its call journal
+type RecordedCall struct {
+	Method  string
+	Args    []any
+	Time    time.Time
+	Matched bool
 }
 
 type Matcher interface {
@@ -28,6 +55,27 @@ type Mocker struct {
 	// in case no other calls in expectedCalls matched
 	defaultCalls map[string]*SingleExpectedCall
 
+	// journal holds all the calls received by the mocker, in the order they were received
+	journal []RecordedCall
+
+	// methods holds the descriptors of the methods registered to the mocker, by their full gRPC method name
+	methods map[string]protoreflect.MethodDescriptor
+
+	// faults holds the fault injectors of the methods by their full gRPC method name, and the global one by ""
+	faults map[string]*faultInjector
+
+	// clock tells the time to the mocker (see Clock)
+	clock Clock
+
+	// scenarios holds the states of the scenarios which left ScenarioStarted, by their name
+	scenarios map[string]string
+
+	// fakeUnmatched, if set, populates the responses of the calls which match nothing (see SetFakeUnmatched)
+	fakeUnmatched *Faker
+
+	// validator, if set, validates the requests and the configured responses (see SetValidator)
+	validator Validator
+
 	mu sync.RWMutex
 	t  *testing.T
 }
@@ -37,7 +85,73 @@ func NewMocker() *Mocker {
 		callCount:     make(map[string]int),
 		expectedCalls: make(map[string][]*SingleExpectedCall),
 		defaultCalls:  make(map[string]*SingleExpectedCall),
+		methods:       make(map[string]protoreflect.MethodDescriptor),
+		faults:        make(map[string]*faultInjector),
+		clock:         realClock{},
+		scenarios:     make(map[string]string),
+	}
+}
+
+type mockerContextKey struct{}
+
+// ContextWithMocker returns a copy of ctx carrying the mocker, so the file stubs of a call use its clock and scenarios.
+// The mock servers call it on the context of each call.
+func (m *Mocker) ContextWithMocker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mockerContextKey{}, m)
+}
+
+// FromContext returns the mocker carried by ctx (see Mocker.ContextWithMocker), or nil if there's none
+func FromContext(ctx context.Context) *Mocker {
+	m, _ := ctx.Value(mockerContextKey{}).(*Mocker)
+	return m
+}
+
+// RegisterService registers the descriptors of all the methods of the given service to the mocker
+func (m *Mocker) RegisterService(sd protoreflect.ServiceDescriptor) {
+	methods := sd.Methods()
+	mds := make([]protoreflect.MethodDescriptor, 0, methods.Len())
+	for i := 0; i < methods.Len(); i++ {
+		mds = append(mds, methods.Get(i))
+	}
+	m.RegisterMethods(mds...)
+}
+
+// RegisterMethods registers the given method descriptors to the mocker, keyed by their full gRPC method name.
+// Registered descriptors allow descriptor-aware features (like building messages of a method dynamically).
+func (m *Mocker) RegisterMethods(mds ...protoreflect.MethodDescriptor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, md := range mds {
+		m.methods[MethodName(md)] = md
+	}
+}
+
+// MethodDescriptor returns the registered descriptor of the given method
+func (m *Mocker) MethodDescriptor(method string) (protoreflect.MethodDescriptor, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	md, ok := m.methods[method]
+	return md, ok
+}
+
+// Methods returns the descriptors of all the methods registered to the mocker, sorted by their full gRPC method name
+func (m *Mocker) Methods() []protoreflect.MethodDescriptor {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.methods))
+	for name := range m.methods {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+
+	mds := make([]protoreflect.MethodDescriptor, 0, len(names))
+	for _, name := range names {
+		mds = append(mds, m.methods[name])
+	}
+	return mds
 }
 
 // SetT sets the `t` attribute of Mocker to log ongoing errors
@@ -54,10 +168,9 @@ func (m *Mocker) LogError(err error) {
 	m.t.Errorf("grpcmock ERROR: %v", err)
 }
 
+// findMatchingCall finds the call matching the given arguments. It must be called with the lock of the mocker held, for
+// writing, so a call gated on a scenario state is matched and transitions its scenario at once (see transition).
 func (m *Mocker) findMatchingCall(method string, args ...any) (*SingleExpectedCall, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
 	calls, ok := m.expectedCalls[method]
 
 	// Try to find a matching call
@@ -65,6 +178,9 @@ func (m *Mocker) findMatchingCall(method string, args ...any) (*SingleExpectedCa
 		if len(call.args) != len(args) {
 			return nil, fmt.Errorf("got unexpected length of argument for methhod %v. Expected %d args, got %d", method, len(call.args), len(args))
 		}
+		if call.scenarioState != "" && m.scenarioState(call.scenario) != call.scenarioState {
+			continue
+		}
 		matches := true
 		for i, arg := range call.args {
 			matcher := Eq(arg)
@@ -89,6 +205,15 @@ func (m *Mocker) findMatchingCall(method string, args ...any) (*SingleExpectedCa
 		return call, nil
 	}
 
+	if md, ok := m.methods[method]; ok && m.fakeUnmatched != nil {
+		faker := m.fakeUnmatched
+		call := newSingleExpectedCallWithFunc([]any{}, func() []any {
+			return faker.fakeReturns(md)
+		})
+		call.setDefault()
+		return &call, nil
+	}
+
 	return nil, ErrNoMatchingCalls{method}
 }
 
@@ -160,14 +285,17 @@ func (m *Mocker) SetDefaultCallWithFunc(method string, doAndReturn DoAndReturn)
 
 // Deprecated: For BC grpcmocks
 func (m *Mocker) Call(method string, args ...any) ([]any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	matchedCall, err := m.findMatchingCall(method, args...)
 	if err != nil {
 		return nil, err
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
 	m.callCount[method]++
+	m.journal = append(m.journal, RecordedCall{Method: method, Args: args, Time: m.clock.Now(), Matched: true})
+	m.transition(matchedCall)
 	matchedCall.call()
 
 	return matchedCall.returns, nil
@@ -175,19 +303,26 @@ func (m *Mocker) Call(method string, args ...any) ([]any, error) {
 
 // CallV2 try to find a matching call for the given method with the given arguments.
 // It will return a struct contains the expected call along with its return values and other information.
-// If no call was found, an error will be returned.
+// If no call was found, an error will be returned, and so if the returned response is invalid (see SetValidator).
 func (m *Mocker) CallV2(method string, args ...any) (*SingleExpectedCall, error) {
 	m.mu.Lock()
 	m.callCount[method]++
-	m.mu.Unlock()
-
+	m.journal = append(m.journal, RecordedCall{Method: method, Args: args, Time: m.clock.Now()})
 	matchedCall, err := m.findMatchingCall(method, args...)
 	if err != nil {
+		m.mu.Unlock()
 		return nil, err
 	}
+	m.journal[len(m.journal)-1].Matched = true
+	m.transition(matchedCall)
+	m.mu.Unlock()
 
 	matchedCall.call()
 
+	if err := m.validateReturns(method, matchedCall.Returns()); err != nil {
+		return nil, err
+	}
+
 	return matchedCall, nil
 }
 
@@ -198,7 +333,51 @@ func (m *Mocker) GetCallCount(method string) int {
 	return m.callCount[method]
 }
 
-// ResetAll deletes all the expected calls and default calls of all methods for this mock server.
+// Calls returns the journal of all the calls received by the mocker, in the order they were received
+func (m *Mocker) Calls() []RecordedCall {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	journal := make([]RecordedCall, len(m.journal))
+	copy(journal, m.journal)
+	return journal
+}
+
+// TestReporter is the part of testing.TB AssertExpectations reports to
+type TestReporter interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertExpectations reports an error to t for every expected call (not including default calls) which was never
+// called, and returns whether all expected calls were called.
+func (m *Mocker) AssertExpectations(t TestReporter) bool {
+	t.Helper()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	methods := make([]string, 0, len(m.expectedCalls))
+	for method := range m.expectedCalls {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	ok := true
+	for _, method := range methods {
+		for _, call := range m.expectedCalls[method] {
+			if call.timesCalled() > 0 {
+				continue
+			}
+			ok = false
+			t.Errorf("grpcmock: expected call to %v with arguments %v was never called", method, call.args)
+		}
+	}
+	return ok
+}
+
+// ResetAll deletes all the expected calls and default calls of all methods for this mock server, along with the
+// calls journal, and resets the scenarios.
 func (m *Mocker) ResetAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -206,6 +385,8 @@ func (m *Mocker) ResetAll() {
 	m.callCount = make(map[string]int)
 	m.expectedCalls = make(map[string][]*SingleExpectedCall)
 	m.defaultCalls = make(map[string]*SingleExpectedCall)
+	m.journal = nil
+	m.scenarios = make(map[string]string)
 }
 
 // ResetCall deletes all the expected call and the default call for a specific method
@@ -254,6 +435,11 @@ type RegisteredCall struct {
 	mocker *Mocker
 }
 
+// ID returns the ID of this call, which can be used to delete it with Mocker.DeleteCall
+func (d *RegisteredCall) ID() string {
+	return d.call.id
+}
+
 // Delete deletes this call from the expected call array.
 func (d *RegisteredCall) Delete() {
 	d.mocker.DeleteCall(d.method, d.call.id)
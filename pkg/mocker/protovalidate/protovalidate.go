@@ -0,0 +1,79 @@
+// Package protovalidate validates the messages of mock servers against their protovalidate (buf.validate) rules. It's
+// kept out of the mocker package, so only the mocks which set its validator (see mocker.Mocker.SetValidator) depend on
+// protovalidate and CEL.
+package protovalidate
+
+import (
+	"errors"
+	"fmt"
+
+	"buf.build/go/protovalidate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Validator validates messages with a protovalidate validator. It implements mocker.Validator: a message which
+// violates its rules fails with an InvalidArgument status and a BadRequest detail of every violation, and broken rules
+// fail with an Internal status.
+type Validator struct {
+	validator protovalidate.Validator
+}
+
+// New returns a Validator validating with validator.
+func New(validator protovalidate.Validator) *Validator {
+	return &Validator{validator: validator}
+}
+
+// Global returns a Validator validating with protovalidate.GlobalValidator.
+func Global() *Validator {
+	return New(protovalidate.GlobalValidator)
+}
+
+func (v *Validator) Validate(msg proto.Message) error {
+	err := v.validator.Validate(msg)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *protovalidate.ValidationError
+	if !errors.As(err, &validationErr) {
+		// The rules themselves are broken, rather than the message
+		err = fmt.Errorf("validate %v: %w", msg.ProtoReflect().Descriptor().FullName(), err)
+		return &statusError{err: err, status: status.New(codes.Internal, err.Error())}
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	for _, violation := range validationErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       protovalidate.FieldPathString(violation.Proto.GetField()),
+			Description: violation.Proto.GetMessage(),
+			Reason:      violation.Proto.GetRuleId(),
+		})
+	}
+	st := status.New(codes.InvalidArgument, validationErr.Error())
+	if withDetails, detailsErr := st.WithDetails(badRequest); detailsErr == nil {
+		st = withDetails
+	}
+	return &statusError{err: err, status: st}
+}
+
+// statusError is a validation error along with its gRPC status, so it reads like the validation error but is
+// returned to the clients as the status
+type statusError struct {
+	err    error
+	status *status.Status
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+func (e *statusError) GRPCStatus() *status.Status {
+	return e.status
+}
@@ -0,0 +1,103 @@
+package mocker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RateLimit simulates a token bucket rate limit, set in a FaultProfile: the bucket holds up to Burst tokens, and is
+// refilled with Requests tokens every Per. Each call takes a token, and calls finding the bucket empty fail with a
+// ResourceExhausted status, with a RetryInfo detail telling when the next token is available.
+// The buckets are refilled according to the clock of the mocker (see Mocker.SetClock), so tests can use a FakeClock
+// instead of waiting.
+type RateLimit struct {
+	Requests int           `yaml:"requests"`
+	Per      time.Duration `yaml:"per"`
+	// Burst is the capacity of the bucket, Requests by default
+	Burst int `yaml:"burst"`
+	// MetadataKey, if set, gives each value of the metadata key of the calls its own bucket, e.g. a bucket per API key.
+	// Calls without the key share a bucket.
+	MetadataKey string `yaml:"metadata_key"`
+}
+
+// Validate returns an error if the rate limit can't be applied
+func (l *RateLimit) Validate() error {
+	if l.Requests <= 0 {
+		return fmt.Errorf("rate limit requests must be positive, got %d", l.Requests)
+	}
+	if l.Per <= 0 {
+		return fmt.Errorf("rate limit period must be positive, got %v", l.Per)
+	}
+	if l.Burst < 0 {
+		return fmt.Errorf("rate limit burst can't be negative, got %d", l.Burst)
+	}
+	return nil
+}
+
+// rateLimiter holds the token buckets of a rate limit
+type rateLimiter struct {
+	limit RateLimit
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	// filled is when tokens was last updated
+	filled time.Time
+}
+
+func newRateLimiter(limit *RateLimit) *rateLimiter {
+	l := &rateLimiter{limit: *limit, buckets: make(map[string]*tokenBucket)}
+	if l.limit.Burst == 0 {
+		l.limit.Burst = l.limit.Requests
+	}
+	return l
+}
+
+// take takes a token from the bucket of the call, and returns the ResourceExhausted status of the call if there's none
+func (l *rateLimiter) take(ctx context.Context, now time.Time) error {
+	var key string
+	if l.limit.MetadataKey != "" {
+		if values := metadata.ValueFromIncomingContext(ctx, l.limit.MetadataKey); len(values) > 0 {
+			key = values[0]
+		}
+	}
+	// Tokens per nanosecond
+	rate := float64(l.limit.Requests) / float64(l.limit.Per)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit.Burst), filled: now}
+		l.buckets[key] = bucket
+	}
+	if elapsed := now.Sub(bucket.filled); elapsed > 0 {
+		bucket.tokens = math.Min(float64(l.limit.Burst), bucket.tokens+float64(elapsed)*rate)
+		bucket.filled = now
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return nil
+	}
+
+	retryDelay := time.Duration(math.Ceil((1 - bucket.tokens) / rate))
+	st, err := status.New(codes.ResourceExhausted, "rate limit exceeded").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryDelay),
+	})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return st.Err()
+}
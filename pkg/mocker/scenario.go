@@ -0,0 +1,88 @@
+package mocker
+
+// Scenarios are named state machines gating expected calls, like WireMock scenarios: a call gated on a scenario state
+// (see RegisteredCall.InScenarioState) only matches while the scenario is in that state, and a matched call may
+// transition its scenario to another state (see RegisteredCall.TransitionTo), so flows like create -> get -> delete ->
+// get can be mocked without closures. Scenarios start in ScenarioStarted. The scenario named "" is the default one.
+
+// ScenarioStarted is the state of the scenarios which never transitioned
+const ScenarioStarted = "Started"
+
+// ScenarioState returns the current state of a scenario
+func (m *Mocker) ScenarioState(scenario string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.scenarioState(scenario)
+}
+
+func (m *Mocker) scenarioState(scenario string) string {
+	if state, ok := m.scenarios[scenario]; ok {
+		return state
+	}
+	return ScenarioStarted
+}
+
+// SetScenarioState sets the current state of a scenario
+func (m *Mocker) SetScenarioState(scenario, state string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scenarios[scenario] = state
+}
+
+// Scenarios returns the current states of the scenarios which left ScenarioStarted, by their name
+func (m *Mocker) Scenarios() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scenarios := make(map[string]string, len(m.scenarios))
+	for scenario, state := range m.scenarios {
+		scenarios[scenario] = state
+	}
+	return scenarios
+}
+
+// ResetScenarios sets all the scenarios back to ScenarioStarted. ResetAll resets them too.
+func (m *Mocker) ResetScenarios() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scenarios = make(map[string]string)
+}
+
+// InScenarioState gates the call on a scenario: it only matches while the scenario is in state. An empty state doesn't
+// gate the call, but still sets the scenario transitioned by TransitionTo.
+func (d *RegisteredCall) InScenarioState(scenario, state string) *RegisteredCall {
+	d.mocker.mu.Lock()
+	defer d.mocker.mu.Unlock()
+	d.call.scenario = scenario
+	d.call.scenarioState = state
+	return d
+}
+
+// TransitionTo transitions the scenario of the call (see InScenarioState) to state whenever the call matches
+func (d *RegisteredCall) TransitionTo(state string) *RegisteredCall {
+	d.mocker.mu.Lock()
+	defer d.mocker.mu.Unlock()
+	d.call.transitionTo = state
+	return d
+}
+
+// transition transitions the scenario of a matched call. It must be called with the lock of the mocker held.
+func (m *Mocker) transition(call *SingleExpectedCall) {
+	if call.transitionTo != "" {
+		m.scenarios[call.scenario] = call.transitionTo
+	}
+}
+
+// CompareAndTransition transitions a scenario from state to transitionTo at once, and reports whether it was in state.
+// An empty state matches any state, and an empty transitionTo leaves the scenario as is.
+func (m *Mocker) CompareAndTransition(scenario, state, transitionTo string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if state != "" && m.scenarioState(scenario) != state {
+		return false
+	}
+	if transitionTo != "" {
+		m.scenarios[scenario] = transitionTo
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package mocker
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// replayedStream is a server stream which receives given messages before the messages of the client stream
+type replayedStream struct {
+	grpc.ServerStream
+	received []proto.Message
+}
+
+// ReplayedStream returns a server stream which receives the given messages (already received from stream) first, and
+// then the rest of the messages of stream. It hands a client stream over to another handler midway, e.g. a fallback
+// implementation of the service.
+func ReplayedStream(stream grpc.ServerStream, received []proto.Message) grpc.ServerStream {
+	return &replayedStream{ServerStream: stream, received: received}
+}
+
+func (s *replayedStream) RecvMsg(m any) error {
+	if len(s.received) == 0 {
+		return s.ServerStream.RecvMsg(m)
+	}
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return fmt.Errorf("received message of type %T isn't a proto message", m)
+	}
+	proto.Reset(msg)
+	proto.Merge(msg, s.received[0])
+	s.received = s.received[1:]
+	return nil
+}
+
+// contextStream is a server stream with another context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,88 @@
+package mocker
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Validator validates messages against their rules, e.g. the buf.validate rules (see the mocker/protovalidate
+// package). An error which has a gRPC status (e.g. InvalidArgument with BadRequest details) is returned as is by
+// ValidateRequest.
+type Validator interface {
+	Validate(msg proto.Message) error
+}
+
+// SetValidator makes the mocker validate the messages against their rules with validator (e.g.
+// protovalidate.Global() of the mocker/protovalidate package), like real servers do:
+//   - The generated mock servers reject the requests which violate their rules (see ValidateRequest), before matching
+//     them.
+//   - The responses returned by expected calls and default returns which violate their rules fail the call (see
+//     CallV2), so tests can't configure responses the real server would never send.
+//
+// A nil validator disables the validation, which is the default. ResetAll keeps it.
+func (m *Mocker) SetValidator(validator Validator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.validator = validator
+}
+
+// ValidateRequest validates req if a validator is set (see SetValidator). A request which violates its rules returns
+// the status of the validation error, or InvalidArgument if it has none.
+func (m *Mocker) ValidateRequest(req proto.Message) error {
+	m.mu.RLock()
+	validator := m.validator
+	m.mu.RUnlock()
+	if validator == nil {
+		return nil
+	}
+
+	err := validator.Validate(req)
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok {
+		if st.Code() == codes.Internal {
+			m.LogError(err)
+		}
+		return st.Err()
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// validateReturns validates the response messages returned by a call against their buf.validate rules, if a validator
+// is set. Calls returning an error aren't validated.
+func (m *Mocker) validateReturns(method string, returns []any) error {
+	m.mu.RLock()
+	validator := m.validator
+	m.mu.RUnlock()
+	if validator == nil || len(returns) != 2 || returns[1] != nil {
+		return nil
+	}
+
+	// A message, or a slice of messages for server streaming methods
+	var responses []proto.Message
+	if res, ok := returns[0].(proto.Message); ok {
+		responses = append(responses, res)
+	} else if v := reflect.ValueOf(returns[0]); v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			if res, ok := v.Index(i).Interface().(proto.Message); ok {
+				responses = append(responses, res)
+			}
+		}
+	}
+
+	for _, res := range responses {
+		if res == nil || !res.ProtoReflect().IsValid() {
+			continue
+		}
+		if err := validator.Validate(res); err != nil {
+			return fmt.Errorf("the configured response of method %v is invalid: %v", MethodShortName(method), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package stub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// casesFileSuffixes are the suffixes of multi-case stub files, loaded by MapStubFiles alongside request/response pairs
+var casesFileSuffixes = []string{".stubs.yaml", ".stubs.yml", ".stubs.json"}
+
+// CasesFile is a single stub file listing many cases of a method, written in YAML or JSON. For example:
+//
+//	method: CreateAccount # or the full gRPC method name, e.g. /accounts.v1.AccountService/CreateAccount
+//	cases:
+//	  - name: existing account
+//	    request: {name: taken}
+//	    status: {code: ALREADY_EXISTS, message: account already exists}
+//	  - name: any other account
+//	    response: {id: "1234"}
+//	    headers: {x-request-id: abcd}
+//	    trailers: {x-trailer: value}
+//	    delay: 100ms
+//
+// Cases of streaming methods may list the server stream messages, and the expected client stream messages:
+//
+//	method: Chat
+//	cases:
+//	  - requests: [{text: hi}, {text: bye}]
+//	    messages:
+//	      - message: {text: hello}
+//	      - message: {text: goodbye}
+//	        delay: 1s
+//	    status: {code: ABORTED}
+//
+// Cases are matched in order, the same way request files are matched, unless given a priority. Requests can be matched
+// exactly instead of as a subset, and with placeholders, JSONPath predicates and CEL expressions:
+//
+//	method: GetAccount
+//	match: exact # the default match mode of the cases of the file
+//	cases:
+//	  - request: {id: "$regex:^acc-[0-9]+$", view: "$absent"}
+//	    priority: 10
+//	  - match: subset
+//	    jsonpath: {"$.filter.owner": "$any"}
+//	    cel: request.page_size > 100 && metadata["x-tenant"] == "acme"
+//
+// See MatchExact, MatchSubset, AnyPlaceholder, AbsentPlaceholder and RegexPrefix.
+type CasesFile struct {
+	Method string `json:"method"`
+	// Match is the match mode of the cases which don't set their own
+	Match string `json:"match,omitempty"`
+	Cases []Case `json:"cases"`
+}
+
+// Case is a single stubbed response of a method
+type Case struct {
+	// Name describes the case in errors and logs
+	Name string `json:"name,omitempty"`
+	// Request is matched the same way request files are matched. An empty request matches any request.
+	Request json.RawMessage `json:"request,omitempty"`
+	// Requests, if set, is the whole sequence of messages expected from a client stream, instead of Request.
+	// It matches a stream whose messages match the requests, in order, once the client closes it.
+	Requests []json.RawMessage `json:"requests,omitempty"`
+	// Match is the match mode of the request (MatchSubset by default, or MatchExact)
+	Match string `json:"match,omitempty"`
+	// JSONPath are predicates the JSON form of the request must match, keyed by JSONPath expressions, e.g.
+	// {"$.items[0].sku": "$regex:^SKU-"}. The values are matched like request values.
+	JSONPath map[string]any `json:"jsonpath,omitempty"`
+	// CEL is an expression which must evaluate to true for the request to match. It has the `request` variable (the
+	// request message, with its proto field names) and the `metadata` variable (the first value of each metadata key).
+	CEL string `json:"cel,omitempty"`
+	// Priority orders the matching of the stubs of a method: stubs of higher priority are matched first
+	Priority int `json:"priority,omitempty"`
+	// Response is the JSON of the response message
+	Response json.RawMessage `json:"response,omitempty"`
+	// Messages are the response messages of a server stream, sent in order instead of Response
+	Messages []StreamMessage `json:"messages,omitempty"`
+	// Status, if set, is the error status returned instead of the response, or after the stream messages
+	Status *Status `json:"status,omitempty"`
+	// Headers are sent as the response header metadata
+	Headers map[string]string `json:"headers,omitempty"`
+	// Trailers are sent as the response trailer metadata
+	Trailers map[string]string `json:"trailers,omitempty"`
+	// Delay is waited before responding
+	Delay Duration `json:"delay,omitempty"`
+	// Scenario, State and TransitionTo gate the case on a scenario of the mocker (see mocker.ScenarioStarted): the case
+	// only matches while the scenario is in State (in any state if empty), and transitions it to TransitionTo, if set,
+	// once it matches. The scenario named "" is the default one.
+	Scenario     string `json:"scenario,omitempty"`
+	State        string `json:"state,omitempty"`
+	TransitionTo string `json:"transitionTo,omitempty"`
+}
+
+// StreamMessage is a single message of a stubbed server stream
+type StreamMessage struct {
+	Message json.RawMessage `json:"message"`
+	// Delay is waited before sending the message
+	Delay Duration `json:"delay,omitempty"`
+}
+
+// Status is a gRPC error status of a stubbed response
+type Status struct {
+	// Code is the name of the status code, e.g. NOT_FOUND
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+	// Details are the JSON forms of google.protobuf.Any messages (see Status.ToGRPCStatus)
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// Duration is a time.Duration written as a duration string (e.g. "1.5s") in stub files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string, e.g. \"100ms\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func isCasesFile(fileName string) bool {
+	for _, suffix := range casesFileSuffixes {
+		if strings.HasSuffix(fileName, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadCasesFile reads and parses a multi-case stub file. YAML files are converted to JSON, so both formats share the
+// same schema. Unknown fields are rejected.
+func LoadCasesFile(path string) (*CasesFile, error) {
+	casesFile := &CasesFile{}
+	if err := decodeStubFile(path, casesFile); err != nil {
+		return nil, err
+	}
+
+	if casesFile.Method == "" {
+		return nil, fmt.Errorf("stub file %q has no method", path)
+	}
+	if !isMatchMode(casesFile.Match) {
+		return nil, fmt.Errorf("stub file %q has an invalid match mode %q", path, casesFile.Match)
+	}
+	for i, c := range casesFile.Cases {
+		if !isMatchMode(c.Match) {
+			return nil, fmt.Errorf("stub file %q: case %s has an invalid match mode %q", path, caseName(i, c), c.Match)
+		}
+		if c.Match == "" {
+			casesFile.Cases[i].Match = casesFile.Match
+		}
+		if err := c.check(); err != nil {
+			return nil, fmt.Errorf("stub file %q: case %s %v", path, caseName(i, c), err)
+		}
+	}
+	return casesFile, nil
+}
+
+// decodeStubFile reads a YAML or JSON stub file into v. YAML files are converted to JSON first, and unknown fields are
+// rejected.
+func decodeStubFile(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read stub file %q: %w", path, err)
+	}
+
+	if filepath.Ext(path) != ".json" {
+		var content any
+		if err = yaml.Unmarshal(b, &content); err != nil {
+			return fmt.Errorf("parse YAML stub file %q: %w", path, err)
+		}
+		if b, err = json.Marshal(content); err != nil {
+			return fmt.Errorf("convert YAML stub file %q to JSON: %w", path, err)
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(v); err != nil {
+		return fmt.Errorf("parse stub file %q: %w", path, err)
+	}
+	return nil
+}
+
+// check returns an error describing the conflicting fields of the case, if any
+func (c Case) check() error {
+	if c.Status != nil && c.Response != nil {
+		return errors.New("has both a response and a status")
+	}
+	if c.Messages != nil && c.Response != nil {
+		return errors.New("has both a response and stream messages")
+	}
+	if c.Requests != nil && c.Request != nil {
+		return errors.New("has both a request and a requests sequence")
+	}
+	return nil
+}
+
+func isMatchMode(mode string) bool {
+	return mode == "" || mode == MatchSubset || mode == MatchExact
+}
+
+// methodKey returns the key of a method in MethodFileStubs, which is its short name
+func methodKey(method string) string {
+	return method[strings.LastIndex(method, "/")+1:]
+}
+
+func caseName(i int, c Case) string {
+	if c.Name == "" {
+		return fmt.Sprintf("#%d", i)
+	}
+	return fmt.Sprintf("#%d (%q)", i, c.Name)
+}
@@ -0,0 +1,228 @@
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nsf/jsondiff"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Cassette is a recorded session of calls across methods, in the order they were made, written in YAML or JSON. For
+// example:
+//
+//	interactions:
+//	  - method: /accounts.v1.AccountService/CreateAccount
+//	    request: {name: john}
+//	    response: {id: "1234"}
+//	  - method: /accounts.v1.AccountService/GetAccount
+//	    request: {id: "1234"}
+//	    response: {id: "1234", name: john}
+//
+// Interactions have the same fields as the cases of a CasesFile (see Case), except for method, which must be the full
+// gRPC method name. Their requests are matched exactly, unless their match mode is set to MatchSubset.
+// Cassettes are recorded with NewCassetteRecorder, and replayed with a Replayer.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single call of a Cassette
+type Interaction struct {
+	// Method is the full gRPC method name, e.g. /accounts.v1.AccountService/CreateAccount
+	Method string `json:"method"`
+	Case
+}
+
+// LoadCassette reads and parses a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	cassette := &Cassette{}
+	if err := decodeStubFile(path, cassette); err != nil {
+		return nil, err
+	}
+
+	for i, interaction := range cassette.Interactions {
+		if !strings.HasPrefix(interaction.Method, "/") || strings.Count(interaction.Method, "/") != 2 {
+			return nil, fmt.Errorf("cassette %q: interaction %s must have a full gRPC method name, e.g. /pkg.Service/Method, got %q", path, caseName(i, interaction.Case), interaction.Method)
+		}
+		if !isMatchMode(interaction.Match) {
+			return nil, fmt.Errorf("cassette %q: interaction %s has an invalid match mode %q", path, caseName(i, interaction.Case), interaction.Match)
+		}
+		if err := interaction.check(); err != nil {
+			return nil, fmt.Errorf("cassette %q: interaction %s %v", path, caseName(i, interaction.Case), err)
+		}
+	}
+	return cassette, nil
+}
+
+// ErrCassetteDiverged is returned when a call doesn't match the next interaction of a replayed cassette
+type ErrCassetteDiverged struct {
+	// Index is the index of the interaction the call was matched against, which is the number of interactions already
+	// replayed
+	Index int
+	// Expected is the interaction the call was matched against, or nil if all the interactions were already replayed
+	Expected *Interaction
+	// Method and Requests are the full gRPC method name and the JSON requests of the call
+	Method   string
+	Requests []json.RawMessage
+	// Reason describes why the call doesn't match the interaction
+	Reason string
+}
+
+func (e ErrCassetteDiverged) Error() string {
+	if e.Expected == nil {
+		return fmt.Sprintf("call #%d of %v diverged from the cassette, which has only %d interaction(s)", e.Index, e.Method, e.Index)
+	}
+	return fmt.Sprintf("call #%d diverged from the cassette: %s. Diff of the interaction and the call:\n%s", e.Index, e.Reason, e.diff())
+}
+
+// diff returns the JSON diff of the method and requests of the expected interaction and of the call
+func (e ErrCassetteDiverged) diff() string {
+	type call struct {
+		Method   string            `json:"method"`
+		Request  json.RawMessage   `json:"request,omitempty"`
+		Requests []json.RawMessage `json:"requests,omitempty"`
+	}
+	expected := call{Method: e.Expected.Method, Request: e.Expected.Request, Requests: e.Expected.Requests}
+	got := call{Method: e.Method, Requests: e.Requests}
+	if e.Expected.Requests == nil && len(e.Requests) == 1 {
+		got = call{Method: e.Method, Request: e.Requests[0]}
+	}
+	expectedJSON, _ := json.Marshal(expected)
+	gotJSON, _ := json.Marshal(got)
+
+	_, diff := jsondiff.Compare(expectedJSON, gotJSON, &cassetteDiffOptions)
+	return diff
+}
+
+// cassetteDiffOptions format diffs in plain text, with changed values as "expected => got" and added or removed
+// values marked with + and -
+var cassetteDiffOptions = jsondiff.Options{
+	Added:            jsondiff.Tag{Begin: "+"},
+	Removed:          jsondiff.Tag{Begin: "-"},
+	ChangedSeparator: " => ",
+	Indent:           "  ",
+}
+
+// Replayer serves the interactions of a cassette in order: each call must match the next interaction, or the replay
+// diverges. A diverged call fails with an ErrCassetteDiverged, and is matched against the same interaction again.
+type Replayer struct {
+	cassette *Cassette
+	// name describes the cassette in stub errors
+	name string
+
+	mu       sync.Mutex
+	next     int
+	diverged error
+}
+
+// NewReplayer creates a replayer of the interactions of the cassette
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{cassette: cassette, name: "cassette"}
+}
+
+// LoadReplayer creates a replayer of the cassette file of path (see LoadCassette)
+func LoadReplayer(path string) (*Replayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	replayer := NewReplayer(cassette)
+	replayer.name = path
+	return replayer, nil
+}
+
+// Replay matches a call of a unary or server streaming method against the next interaction of the cassette. If it
+// matches, res is filled with the interaction response message and the rest of the response is returned. Otherwise, an
+// ErrCassetteDiverged is returned.
+// method is the full gRPC method name.
+func (r *Replayer) Replay(ctx context.Context, method string, req, res proto.Message) (*Response, error) {
+	gotJSON, err := protojson.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request JSON: %w", err)
+	}
+	return r.replay(ctx, method, [][]byte{gotJSON}, res, false, requestMatcher(ctx, req, gotJSON))
+}
+
+// ReplayStream is like Replay, for the whole sequence of messages received on a client stream
+func (r *Replayer) ReplayStream(ctx context.Context, method string, reqs []proto.Message, res proto.Message) (*Response, error) {
+	gotJSONs := make([][]byte, 0, len(reqs))
+	for _, req := range reqs {
+		gotJSON, err := protojson.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request JSON: %w", err)
+		}
+		gotJSONs = append(gotJSONs, gotJSON)
+	}
+	return r.replay(ctx, method, gotJSONs, res, true, streamMatcher(gotJSONs))
+}
+
+func (r *Replayer) replay(ctx context.Context, method string, requestsJSON [][]byte, res proto.Message, clientStream bool, matches stubMatcher) (*Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	diverged := ErrCassetteDiverged{Index: r.next, Method: method}
+	for _, reqJSON := range requestsJSON {
+		diverged.Requests = append(diverged.Requests, reqJSON)
+	}
+	if r.next >= len(r.cassette.Interactions) {
+		return nil, r.diverge(diverged)
+	}
+
+	interaction := r.cassette.Interactions[r.next]
+	diverged.Expected = &interaction
+	if interaction.Method != method {
+		diverged.Reason = fmt.Sprintf("expected a call of %v, got a call of %v", interaction.Method, method)
+		return nil, r.diverge(diverged)
+	}
+
+	c := interaction.Case
+	if c.Match == "" {
+		c.Match = MatchExact
+	}
+	if clientStream && c.Requests == nil && c.Request == nil {
+		// Recorded client streams without messages have no requests
+		c.Requests = []json.RawMessage{}
+	}
+	stubFile := MethodFileStub{CasesFilePath: r.name, Case: &c, CaseIndex: r.next, Method: method}
+	reason, err := matches(stubFile)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		diverged.Reason = reason
+		return nil, r.diverge(diverged)
+	}
+	key := methodKey(method)
+	response, err := findStub(ctx, MethodFileStubs{key: {stubFile}}, key, requestsJSON, res, matches)
+	if err != nil {
+		return nil, err
+	}
+	r.next++
+	return response, nil
+}
+
+func (r *Replayer) diverge(err ErrCassetteDiverged) error {
+	if r.diverged == nil {
+		r.diverged = err
+	}
+	return err
+}
+
+// Verify returns an error if the replay diverged from the cassette (the first divergence), or if some interactions
+// weren't replayed. It's meant to be checked once the session is over, e.g. at the end of a test.
+func (r *Replayer) Verify() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.diverged != nil {
+		return r.diverged
+	}
+	if unplayed := len(r.cassette.Interactions) - r.next; unplayed > 0 {
+		return fmt.Errorf("%d interaction(s) of the cassette weren't replayed, starting with interaction #%d of %v", unplayed, r.next, r.cassette.Interactions[r.next].Method)
+	}
+	return nil
+}
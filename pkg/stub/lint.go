@@ -0,0 +1,227 @@
+package stub
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// LintIssue is a problem of a stub found by Lint
+type LintIssue struct {
+	Stub    MethodFileStub
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("stub %v: %s", i.Stub, i.Message)
+}
+
+// Lint checks the stubs against the methods of the given services, and returns their issues:
+//   - stubs which aren't valid for their method (see Validate)
+//   - stubs of methods which aren't in the services
+//   - stubs which are never matched, as a stub of the same method matched before them (see MethodFileStub.Priority)
+//     matches every request they match. Stubs matching the same requests the same way are reported as duplicates.
+//     Stubs gated on another scenario state (see Case.State) than the stub before them are never reported.
+//
+// The issues are ordered by method name, and by stub within a method.
+func Lint(stubs MethodFileStubs, services ...protoreflect.ServiceDescriptor) []LintIssue {
+	methodsByName := map[string][]protoreflect.MethodDescriptor{}
+	for _, sd := range services {
+		for i := 0; i < sd.Methods().Len(); i++ {
+			md := sd.Methods().Get(i)
+			methodsByName[string(md.Name())] = append(methodsByName[string(md.Name())], md)
+		}
+	}
+
+	var issues []LintIssue
+	for _, key := range sortedKeys(stubs) {
+		for _, stub := range stubs[key] {
+			known := false
+			for _, md := range methodsByName[key] {
+				if !stub.isOf(md) {
+					continue
+				}
+				known = true
+				if err := validateStub(md, stub); err != nil {
+					issues = append(issues, LintIssue{Stub: stub, Message: fmt.Sprintf("invalid for %v: %v", md.FullName(), err)})
+				}
+			}
+			if !known {
+				method := stub.Method
+				if method == "" {
+					method = key
+				}
+				issues = append(issues, LintIssue{Stub: stub, Message: fmt.Sprintf("unknown method %q", method)})
+			}
+		}
+		if len(methodsByName[key]) == 0 {
+			issues = append(issues, lintUnreachable(stubs[key])...)
+			continue
+		}
+		// Stubs given a bare method name are of the methods of every service, so they may be reported once per method
+		reported := map[string]bool{}
+		for _, md := range methodsByName[key] {
+			for _, issue := range lintUnreachable(methodStubs(stubs, mocker.MethodName(md))) {
+				if !reported[issue.String()] {
+					reported[issue.String()] = true
+					issues = append(issues, issue)
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// lintUnreachable reports the stubs of a method which are never matched, in matching order
+func lintUnreachable(stubs []MethodFileStub) []LintIssue {
+	ordered := make([]MethodFileStub, len(stubs))
+	copy(ordered, stubs)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Priority() > ordered[j].Priority()
+	})
+
+	var issues []LintIssue
+	for i, later := range ordered {
+		for _, earlier := range ordered[:i] {
+			if sameCriteria(earlier, later) {
+				issues = append(issues, LintIssue{Stub: later, Message: fmt.Sprintf("duplicate of stub %v, which is matched first", earlier)})
+				break
+			}
+			if shadows(earlier, later) {
+				issues = append(issues, LintIssue{Stub: later, Message: fmt.Sprintf("never matched, as stub %v is matched first for every request it matches", earlier)})
+				break
+			}
+		}
+	}
+	return issues
+}
+
+// matchCriteria are everything a stub matches requests with
+type matchCriteria struct {
+	request  any
+	sequence []any
+	mode     string
+	jsonPath map[string]any
+	cel      string
+	// scenario and state gate the matching (see Case.State)
+	scenario string
+	state    string
+}
+
+// criteria returns the match criteria of the stub, or false if they can't be read (which is reported by the validation)
+func (s MethodFileStub) criteria() (matchCriteria, bool) {
+	c := matchCriteria{mode: s.matchMode()}
+	sequence, err := s.RequestSequence()
+	if err != nil {
+		return c, false
+	}
+	if sequence != nil {
+		if c.sequence, err = s.sequenceValues(); err != nil {
+			return c, false
+		}
+		// Distinguishing an empty sequence from a single request
+		c.sequence = append([]any{}, c.sequence...)
+	} else if c.request, err = s.requestValue(); err != nil {
+		return c, false
+	}
+	if s.Case != nil {
+		c.jsonPath = s.Case.JSONPath
+		c.cel = s.Case.CEL
+		c.scenario = s.Case.Scenario
+		c.state = s.Case.State
+	}
+	return c, true
+}
+
+func sameCriteria(a, b MethodFileStub) bool {
+	aCriteria, aOK := a.criteria()
+	bCriteria, bOK := b.criteria()
+	return aOK && bOK && reflect.DeepEqual(aCriteria, bCriteria)
+}
+
+// shadows returns whether the earlier stub matches every request the later stub matches. It errs on the side of false:
+// stubs with JSONPath predicates or CEL expressions, matched exactly, or gated on another scenario state are never
+// considered shadowing.
+func shadows(earlier, later MethodFileStub) bool {
+	e, eOK := earlier.criteria()
+	l, lOK := later.criteria()
+	if !eOK || !lOK || e.mode == MatchExact || len(e.jsonPath) > 0 || e.cel != "" {
+		return false
+	}
+	if e.scenario != l.scenario || e.state != l.state {
+		return false
+	}
+	if (e.sequence == nil) != (l.sequence == nil) {
+		return false
+	}
+	if e.sequence == nil {
+		return covers(e.request, l.request)
+	}
+
+	if len(e.sequence) != len(l.sequence) {
+		return false
+	}
+	for i := range e.sequence {
+		if !covers(e.sequence[i], l.sequence[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// covers returns whether every value matching the stub request value later also matches the stub request value
+// earlier, in subset mode
+func covers(earlier, later any) bool {
+	switch e := earlier.(type) {
+	case string:
+		if e == AnyPlaceholder {
+			return later != AbsentPlaceholder
+		}
+		if e == AbsentPlaceholder {
+			return later == AbsentPlaceholder
+		}
+		if strings.HasPrefix(e, RegexPrefix) {
+			re, err := compileRegex(strings.TrimPrefix(e, RegexPrefix))
+			if err != nil || isPlaceholder(later) {
+				return false
+			}
+			laterString, ok := scalarString(later)
+			return ok && re.MatchString(laterString)
+		}
+	case map[string]any:
+		l, ok := later.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, value := range e {
+			laterValue, present := l[key]
+			if value == AbsentPlaceholder {
+				if laterValue != AbsentPlaceholder {
+					return false
+				}
+				continue
+			}
+			if !present || !covers(value, laterValue) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		l, ok := later.([]any)
+		if !ok || len(l) < len(e) {
+			return false
+		}
+		for i := range e {
+			if !covers(e[i], l[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return !isPlaceholder(later) && reflect.DeepEqual(earlier, later)
+}
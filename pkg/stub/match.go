@@ -0,0 +1,331 @@
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/google/cel-go/cel"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Match modes of stub requests
+const (
+	// MatchSubset matches requests whose JSON is equal to, or a superset of, the stub request JSON. It's the default.
+	MatchSubset = "subset"
+	// MatchExact matches requests whose JSON is equal to the stub request JSON
+	MatchExact = "exact"
+)
+
+// Placeholders which can be used as values in stub requests and JSONPath predicates
+const (
+	// AnyPlaceholder matches any value of a field, as long as it's set
+	AnyPlaceholder = "$any"
+	// AbsentPlaceholder matches fields which aren't set (or set to their default value)
+	AbsentPlaceholder = "$absent"
+	// RegexPrefix prefixes a regular expression matching the string form of a value, e.g. "$regex:^acc-[0-9]+$"
+	RegexPrefix = "$regex:"
+)
+
+// matchRequest returns why the request doesn't match the stub, or an empty string if it matches.
+// got is the request and gotValue is its decoded JSON form.
+func (s MethodFileStub) matchRequest(ctx context.Context, got proto.Message, gotValue any) (string, error) {
+	want, err := s.requestValue()
+	if err != nil {
+		return "", err
+	}
+	if reason := matchJSON(gotValue, want, s.matchMode() == MatchExact, "$"); reason != "" {
+		return reason, nil
+	}
+
+	if s.Case == nil {
+		return "", nil
+	}
+	if len(s.Case.JSONPath) > 0 {
+		if reason, err := matchJSONPath(gotValue, s.Case.JSONPath); reason != "" || err != nil {
+			return reason, err
+		}
+	}
+	if s.Case.CEL != "" {
+		return matchCEL(ctx, got, s.Case.CEL)
+	}
+	return "", nil
+}
+
+// matchSequence returns why the messages of a client stream, as decoded JSON, don't match the requests sequence of the
+// stub, or an empty string if they match
+func (s MethodFileStub) matchSequence(gotValues []any) (string, error) {
+	sequence, err := s.sequenceValues()
+	if err != nil {
+		return "", err
+	}
+	if len(sequence) != len(gotValues) {
+		return fmt.Sprintf("expected a stream of %d messages, got %d", len(sequence), len(gotValues)), nil
+	}
+	for i, want := range sequence {
+		if reason := matchJSON(gotValues[i], want, s.matchMode() == MatchExact, "$"); reason != "" {
+			return fmt.Sprintf("message #%d: %s", i, reason), nil
+		}
+	}
+	return "", nil
+}
+
+func (s MethodFileStub) matchMode() string {
+	if s.Case != nil && s.Case.Match != "" {
+		return s.Case.Match
+	}
+	return MatchSubset
+}
+
+// requestValue returns the decoded request JSON of the stub
+func (s MethodFileStub) requestValue() (any, error) {
+	if s.loaded != nil && s.loaded.hasValue {
+		return s.loaded.requestValue, nil
+	}
+	stubReqJSON, err := s.RequestJSON()
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err = json.Unmarshal(stubReqJSON, &value); err != nil {
+		return nil, fmt.Errorf("stub %v contains an invalid request JSON", s)
+	}
+	return value, nil
+}
+
+// sequenceValues returns the decoded requests sequence of the stub
+func (s MethodFileStub) sequenceValues() ([]any, error) {
+	if s.loaded != nil && s.loaded.hasValue {
+		return s.loaded.sequenceValues, nil
+	}
+	sequence, err := s.RequestSequence()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, len(sequence))
+	for i, reqJSON := range sequence {
+		if err = json.Unmarshal(reqJSON, &values[i]); err != nil {
+			return nil, fmt.Errorf("stub %v contains an invalid request #%d JSON", s, i)
+		}
+	}
+	return values, nil
+}
+
+// matchJSON returns why got doesn't match want, or an empty string if it matches. In subset mode, objects may have
+// fields which aren't in want and arrays may have more elements than want.
+func matchJSON(got, want any, exact bool, path string) string {
+	switch w := want.(type) {
+	case string:
+		if w == AnyPlaceholder {
+			return ""
+		}
+		if strings.HasPrefix(w, RegexPrefix) {
+			re, err := compileRegex(strings.TrimPrefix(w, RegexPrefix))
+			if err != nil {
+				return fmt.Sprintf("%s: %v", path, err)
+			}
+			gotString, ok := scalarString(got)
+			if !ok || !re.MatchString(gotString) {
+				return fmt.Sprintf("%s: %s doesn't match %q", path, jsonString(got), w)
+			}
+			return ""
+		}
+	case map[string]any:
+		gotObject, ok := got.(map[string]any)
+		if !ok {
+			return fmt.Sprintf("%s: expected an object, got %s", path, jsonString(got))
+		}
+		for _, key := range sortedKeys(w) {
+			fieldPath := path + "." + key
+			gotValue, present := gotObject[key]
+			if w[key] == AbsentPlaceholder {
+				if present {
+					return fmt.Sprintf("%s: expected to be absent, got %s", fieldPath, jsonString(gotValue))
+				}
+				continue
+			}
+			if !present {
+				return fmt.Sprintf("%s: missing, expected %s", fieldPath, jsonString(w[key]))
+			}
+			if reason := matchJSON(gotValue, w[key], exact, fieldPath); reason != "" {
+				return reason
+			}
+		}
+		if exact {
+			for _, key := range sortedKeys(gotObject) {
+				if _, ok := w[key]; !ok {
+					return fmt.Sprintf("%s.%s: unexpected field in exact match", path, key)
+				}
+			}
+		}
+		return ""
+	case []any:
+		gotArray, ok := got.([]any)
+		if !ok {
+			return fmt.Sprintf("%s: expected an array, got %s", path, jsonString(got))
+		}
+		if len(gotArray) < len(w) || (exact && len(gotArray) != len(w)) {
+			return fmt.Sprintf("%s: expected %d elements, got %d", path, len(w), len(gotArray))
+		}
+		for i := range w {
+			if reason := matchJSON(gotArray[i], w[i], exact, fmt.Sprintf("%s[%d]", path, i)); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+
+	if got != want {
+		return fmt.Sprintf("%s: expected %s, got %s", path, jsonString(want), jsonString(got))
+	}
+	return ""
+}
+
+// matchJSONPath returns why got doesn't match all the JSONPath predicates, or an empty string if it matches
+func matchJSONPath(got any, predicates map[string]any) (string, error) {
+	for _, expr := range sortedKeys(predicates) {
+		eval, err := compileJSONPath(expr)
+		if err != nil {
+			return "", err
+		}
+		value, err := eval(context.Background(), got)
+		if predicates[expr] == AbsentPlaceholder {
+			if err == nil && value != nil {
+				return fmt.Sprintf("%s: expected to be absent, got %s", expr, jsonString(value)), nil
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Sprintf("%s: %v", expr, err), nil
+		}
+		if reason := matchJSON(value, predicates[expr], false, expr); reason != "" {
+			return reason, nil
+		}
+	}
+	return "", nil
+}
+
+// matchCEL returns why got doesn't match the CEL expression, or an empty string if it matches
+func matchCEL(ctx context.Context, got proto.Message, expr string) (string, error) {
+	prg, err := compileCEL(got.ProtoReflect().Descriptor(), expr)
+	if err != nil {
+		return "", err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestMetadata := map[string]string{}
+	for key, values := range md {
+		if len(values) > 0 {
+			requestMetadata[key] = values[0]
+		}
+	}
+
+	out, _, err := prg.Eval(map[string]any{"request": got, "metadata": requestMetadata})
+	if err != nil {
+		return fmt.Sprintf("CEL %q: %v", expr, err), nil
+	}
+	if matched, ok := out.Value().(bool); !ok || !matched {
+		return fmt.Sprintf("CEL %q evaluated to %v", expr, out.Value()), nil
+	}
+	return "", nil
+}
+
+var (
+	regexCache    sync.Map // pattern -> *regexp.Regexp
+	jsonPathCache sync.Map // expression -> gval.Evaluable
+	celCache      sync.Map // message full name + expression -> cel.Program
+)
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+func compileJSONPath(expr string) (gval.Evaluable, error) {
+	if eval, ok := jsonPathCache.Load(expr); ok {
+		return eval.(gval.Evaluable), nil
+	}
+	eval, err := jsonpath.New(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", expr, err)
+	}
+	jsonPathCache.Store(expr, eval)
+	return eval, nil
+}
+
+// compileCEL compiles a CEL expression over requests of the given message type. The expression has the `request`
+// variable, of the request message type, and the `metadata` variable, a map of the first value of each metadata key.
+func compileCEL(md protoreflect.MessageDescriptor, expr string) (cel.Program, error) {
+	cacheKey := string(md.FullName()) + "\x00" + expr
+	if prg, ok := celCache.Load(cacheKey); ok {
+		return prg.(cel.Program), nil
+	}
+
+	env, err := cel.NewEnv(
+		cel.TypeDescs(md.ParentFile()),
+		cel.Variable("request", cel.ObjectType(string(md.FullName()))),
+		cel.Variable("metadata", cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid CEL expression %q: %w", expr, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("invalid CEL expression %q: must evaluate to a bool, not %v", expr, ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CEL expression %q: %w", expr, err)
+	}
+	celCache.Store(cacheKey, prg)
+	return prg, nil
+}
+
+// scalarString returns the string form of a JSON scalar value
+func scalarString(v any) (string, bool) {
+	switch value := v.(type) {
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+	return "", false
+}
+
+func jsonString(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
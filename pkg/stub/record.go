@@ -0,0 +1,357 @@
+package stub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Recorder forwards calls to an upstream server and records them as stubs, to bootstrap stubs from a real environment.
+// Each method is recorded into a multi-case stub file (see CasesFile) named after it, e.g.
+// grpcmock.example.ExampleService_ExampleMethod.stubs.json, with a case per distinct request (or requests sequence).
+// Recording a request again replaces its case.
+type Recorder struct {
+	conn grpc.ClientConnInterface
+	// write writes a recorded call of a method
+	write func(method string, c Case) error
+
+	dir string
+	// cassette is the session recorded into the cassette file of cassettePath
+	cassette     *Cassette
+	cassettePath string
+
+	// mu serializes the writes of the stub files
+	mu sync.Mutex
+}
+
+// NewRecorder creates a recorder forwarding calls to conn and writing the stubs into dir
+func NewRecorder(conn grpc.ClientConnInterface, dir string) *Recorder {
+	r := &Recorder{conn: conn, dir: dir}
+	r.write = r.writeCase
+	return r
+}
+
+// NewCassetteRecorder creates a recorder forwarding calls to conn and writing them, in the order they complete, as the
+// interactions of a cassette (see Cassette) into the file of path. The recorded session replaces the content of the file.
+func NewCassetteRecorder(conn grpc.ClientConnInterface, path string) *Recorder {
+	r := &Recorder{conn: conn, cassette: &Cassette{}, cassettePath: path}
+	r.write = r.writeInteraction
+	return r
+}
+
+// Unary forwards a unary call to the upstream server and records it. It fills res with the upstream response, sets the
+// upstream header and trailer metadata on the RPC of ctx, and returns the upstream status error, if any.
+// method is the full gRPC method name.
+func (r *Recorder) Unary(ctx context.Context, method string, req, res proto.Message) error {
+	var header, trailer metadata.MD
+	err := r.conn.Invoke(upstreamContext(ctx), method, req, res, grpc.Header(&header), grpc.Trailer(&trailer))
+	header, trailer = forwardedMetadata(header), forwardedMetadata(trailer)
+	if len(header) > 0 {
+		_ = grpc.SetHeader(ctx, header)
+	}
+	if len(trailer) > 0 {
+		_ = grpc.SetTrailer(ctx, trailer)
+	}
+
+	recorded := &recordedCall{requests: []proto.Message{req}, header: header, trailer: trailer, err: err}
+	if err == nil {
+		recorded.responses = []proto.Message{res}
+	}
+	r.record(method, recorded)
+	return err
+}
+
+// Stream forwards a streaming call to the upstream server and records it. The messages already received from the client
+// stream are sent first, then the rest of the client stream is forwarded while the upstream responses are sent back.
+// req and res are messages of the request and response types of the method.
+func (r *Recorder) Stream(stream grpc.ServerStream, info *grpc.StreamServerInfo, received []proto.Message, req, res proto.Message) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	upstream, err := r.conn.NewStream(upstreamContext(ctx), &grpc.StreamDesc{
+		StreamName:    info.FullMethod,
+		ServerStreams: true,
+		ClientStreams: true,
+	}, info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	recorded := &recordedCall{clientStreams: info.IsClientStream, serverStreams: info.IsServerStream}
+	var mu sync.Mutex
+	forward := func(msg proto.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		// Once the upstream call is done, the rest of the client stream is neither forwarded nor recorded
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		recorded.requests = append(recorded.requests, msg)
+		return upstream.SendMsg(msg)
+	}
+
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for _, msg := range received {
+			if forward(msg) != nil {
+				return
+			}
+		}
+		for info.IsClientStream && ctx.Err() == nil {
+			msg := req.ProtoReflect().New().Interface()
+			if err := stream.RecvMsg(msg); err != nil {
+				break
+			}
+			if forward(msg) != nil {
+				return
+			}
+		}
+		_ = upstream.CloseSend()
+	}()
+
+	if header, err := upstream.Header(); err == nil {
+		recorded.header = forwardedMetadata(header)
+		if len(recorded.header) > 0 {
+			_ = stream.SetHeader(recorded.header)
+		}
+	}
+	// stopForwarding stops the forwarding of the client stream, and waits for it to end. A client of a client stream
+	// closes it before receiving the response, so its stream ends, while a client of a bidirectional stream may wait for
+	// responses first, so its stream may only end once the call returns.
+	stopForwarding := func() {
+		cancel()
+		if !info.IsServerStream {
+			<-forwarded
+		}
+	}
+	for {
+		msg := res.ProtoReflect().New().Interface()
+		err = upstream.RecvMsg(msg)
+		if errors.Is(err, io.EOF) {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		recorded.responses = append(recorded.responses, msg)
+		if err = stream.SendMsg(msg); err != nil {
+			stopForwarding()
+			return err
+		}
+	}
+	stopForwarding()
+
+	recorded.trailer = forwardedMetadata(upstream.Trailer())
+	if len(recorded.trailer) > 0 {
+		stream.SetTrailer(recorded.trailer)
+	}
+
+	recorded.err = err
+	mu.Lock()
+	defer mu.Unlock()
+	r.record(info.FullMethod, recorded)
+	return err
+}
+
+// recordedCall is a call forwarded to the upstream server
+type recordedCall struct {
+	clientStreams bool
+	serverStreams bool
+	requests      []proto.Message
+	responses     []proto.Message
+	header        metadata.MD
+	trailer       metadata.MD
+	err           error
+}
+
+func (r *Recorder) record(method string, call *recordedCall) {
+	c, err := call.toCase()
+	if err == nil {
+		err = r.write(method, c)
+	}
+	if err != nil {
+		log.Printf("Failed recording a call of %v: %v\n", method, err)
+	}
+}
+
+func (call *recordedCall) toCase() (Case, error) {
+	// Recorded requests are matched exactly, as they're complete
+	c := Case{Match: MatchExact, Headers: firstValues(call.header), Trailers: firstValues(call.trailer)}
+
+	requests, err := marshalMessages(call.requests)
+	if err != nil {
+		return c, fmt.Errorf("marshal requests: %w", err)
+	}
+	if call.clientStreams {
+		c.Requests = requests
+	} else if len(requests) > 0 {
+		c.Request = requests[0]
+	}
+
+	responses, err := marshalMessages(call.responses)
+	if err != nil {
+		return c, fmt.Errorf("marshal responses: %w", err)
+	}
+	if call.serverStreams {
+		for _, response := range responses {
+			c.Messages = append(c.Messages, StreamMessage{Message: response})
+		}
+	} else if len(responses) > 0 {
+		c.Response = responses[0]
+	}
+
+	if call.err != nil {
+		st := status.Convert(call.err)
+		c.Status = &Status{Code: CodeName(st.Code()), Message: st.Message()}
+		for _, detail := range st.Proto().GetDetails() {
+			// Details whose type isn't linked into the binary can't be written as JSON
+			if detailJSON, err := protojson.Marshal(detail); err == nil {
+				c.Status.Details = append(c.Status.Details, compactJSON(detailJSON))
+			}
+		}
+	}
+	return c, nil
+}
+
+// writeCase adds the case to the stub file of the method, replacing the case of the same request if there is one
+func (r *Recorder) writeCase(method string, c Case) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := filepath.Join(r.dir, strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", "_")+".stubs.json")
+	casesFile := &CasesFile{Method: method}
+	if _, err := os.Stat(path); err == nil {
+		if casesFile, err = LoadCasesFile(path); err != nil {
+			return err
+		}
+	}
+
+	replaced := false
+	for i := range casesFile.Cases {
+		if bytes.Equal(requestKey(casesFile.Cases[i]), requestKey(c)) {
+			casesFile.Cases[i] = c
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		casesFile.Cases = append(casesFile.Cases, c)
+	}
+
+	return writeStubFile(path, casesFile)
+}
+
+// writeInteraction adds the call to the recorded cassette, and writes the cassette
+func (r *Recorder) writeInteraction(method string, c Case) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Interactions are matched exactly by default
+	c.Match = ""
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{Method: method, Case: c})
+	return writeStubFile(r.cassettePath, r.cassette)
+}
+
+// writeStubFile writes v as the JSON stub file of path
+func writeStubFile(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal stub file: %w", err)
+	}
+	// Writing to a temporary file first, so a watching Store never reads a partially written stub file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".recording-*")
+	if err != nil {
+		return fmt.Errorf("create stub file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write stub file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("write stub file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write stub file: %w", err)
+	}
+	return nil
+}
+
+// requestKey returns the canonical JSON of what a case matches requests with
+func requestKey(c Case) []byte {
+	key, _ := json.Marshal(struct {
+		Request  json.RawMessage   `json:"request,omitempty"`
+		Requests []json.RawMessage `json:"requests,omitempty"`
+	}{compactJSON(c.Request), c.Requests})
+	return key
+}
+
+// upstreamContext returns the context of a call forwarded upstream, with the metadata of the incoming call
+func upstreamContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, forwardedMetadata(md))
+}
+
+// forwardedMetadata returns the metadata without the keys set by the gRPC transports
+func forwardedMetadata(md metadata.MD) metadata.MD {
+	forwarded := metadata.MD{}
+	for key, values := range md {
+		if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") || key == "content-type" || key == "user-agent" {
+			continue
+		}
+		forwarded[key] = values
+	}
+	return forwarded
+}
+
+func firstValues(md metadata.MD) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+	values := map[string]string{}
+	for key, keyValues := range md {
+		if len(keyValues) > 0 {
+			values[key] = keyValues[0]
+		}
+	}
+	return values
+}
+
+func marshalMessages(msgs []proto.Message) ([]json.RawMessage, error) {
+	var marshalled []json.RawMessage
+	for _, msg := range msgs {
+		b, err := protojson.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		marshalled = append(marshalled, compactJSON(b))
+	}
+	return marshalled, nil
+}
+
+// compactJSON returns b without insignificant spaces, as protojson randomizes them
+func compactJSON(b []byte) json.RawMessage {
+	if b == nil {
+		return nil
+	}
+	compacted := &bytes.Buffer{}
+	if err := json.Compact(compacted, b); err != nil {
+		return b
+	}
+	return compacted.Bytes()
+}
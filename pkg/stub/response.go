@@ -0,0 +1,182 @@
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/torqio/grpcmock/pkg/mocker"
+	// Registering the standard error details types, so they can be used in stub statuses
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// Response is everything a matched stub responds with other than the response message: an error status, header and
+// trailer metadata and a delay
+type Response struct {
+	Status   *Status
+	Headers  map[string]string
+	Trailers map[string]string
+	Delay    Duration
+	// Messages are the messages of a server stream (see RespondStream)
+	Messages []StreamMessage
+}
+
+// responseEnvelope is the form of a response file which contains more than the response message, e.g.
+//
+//	{"status": {"code": "NOT_FOUND", "message": "account not found"}, "headers": {"x-request-id": "abcd"}}
+type responseEnvelope struct {
+	Response json.RawMessage   `json:"response,omitempty"`
+	Messages []StreamMessage   `json:"messages,omitempty"`
+	Status   *Status           `json:"status,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Trailers map[string]string `json:"trailers,omitempty"`
+	Delay    Duration          `json:"delay,omitempty"`
+}
+
+var responseEnvelopeKeys = map[string]bool{
+	"response": true, "messages": true, "status": true, "headers": true, "trailers": true, "delay": true,
+}
+
+// parseResponseFile splits the content of a response file into the response message JSON and the rest of the response.
+// A response file is an envelope (see responseEnvelope) if all its keys are envelope keys, and at least one of them
+// isn't a field of the response message. Otherwise, the whole file is the response message.
+func parseResponseFile(b []byte, md protoreflect.MessageDescriptor) ([]byte, *Response, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(b, &keys); err != nil || len(keys) == 0 {
+		// Not an object, left to the message unmarshal to report
+		return b, &Response{}, nil
+	}
+
+	isEnvelope := false
+	for key := range keys {
+		if !responseEnvelopeKeys[key] {
+			return b, &Response{}, nil
+		}
+		if md.Fields().ByJSONName(key) == nil && md.Fields().ByName(protoreflect.Name(key)) == nil {
+			isEnvelope = true
+		}
+	}
+	if !isEnvelope {
+		return b, &Response{}, nil
+	}
+
+	envelope := responseEnvelope{}
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("parse response: %w", err)
+	}
+	msg := []byte(envelope.Response)
+	if len(msg) == 0 {
+		msg = []byte("{}")
+	}
+	return msg, &Response{
+		Status:   envelope.Status,
+		Headers:  envelope.Headers,
+		Trailers: envelope.Trailers,
+		Delay:    envelope.Delay,
+		Messages: envelope.Messages,
+	}, nil
+}
+
+// Err returns the status error of the response, or nil if it has no status
+func (r *Response) Err() error {
+	if r.Status == nil {
+		return nil
+	}
+	st, err := r.Status.ToGRPCStatus()
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return st.Err()
+}
+
+// Respond waits the response delay, sets its headers and trailers on the RPC of ctx and returns its status error, if any.
+// Headers can only be set before the first response message of the RPC is sent.
+func (r *Response) Respond(ctx context.Context) error {
+	if err := wait(ctx, r.Delay); err != nil {
+		return err
+	}
+
+	if len(r.Headers) > 0 {
+		if err := grpc.SetHeader(ctx, metadata.New(r.Headers)); err != nil {
+			return status.Errorf(codes.Internal, "set stub headers: %v", err)
+		}
+	}
+	if len(r.Trailers) > 0 {
+		if err := grpc.SetTrailer(ctx, metadata.New(r.Trailers)); err != nil {
+			return status.Errorf(codes.Internal, "set stub trailers: %v", err)
+		}
+	}
+	return r.Err()
+}
+
+// RespondStream responds on a server stream: it waits the response delay, sets its headers, sends its messages (or res
+// if it has neither messages nor a status), sets its trailers and returns its status error, if any.
+// res is the response message the stub matched with, also used as the type of the stream messages.
+func (r *Response) RespondStream(stream grpc.ServerStream, res proto.Message) error {
+	ctx := stream.Context()
+	if err := wait(ctx, r.Delay); err != nil {
+		return err
+	}
+	if len(r.Headers) > 0 {
+		if err := stream.SetHeader(metadata.New(r.Headers)); err != nil {
+			return status.Errorf(codes.Internal, "set stub headers: %v", err)
+		}
+	}
+
+	if r.Messages == nil && r.Status == nil {
+		if err := stream.SendMsg(res); err != nil {
+			return err
+		}
+	}
+	for i, streamMessage := range r.Messages {
+		if err := wait(ctx, streamMessage.Delay); err != nil {
+			return err
+		}
+		msg := res.ProtoReflect().New().Interface()
+		if err := protojson.Unmarshal(streamMessage.Message, msg); err != nil {
+			return status.Errorf(codes.Internal, "unmarshal stub stream message #%d: %v", i, err)
+		}
+		if err := stream.SendMsg(msg); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Trailers) > 0 {
+		stream.SetTrailer(metadata.New(r.Trailers))
+	}
+	return r.Err()
+}
+
+// wait waits the given delay on the clock of the mocker (see mocker.Wait), or until ctx is done
+func wait(ctx context.Context, delay Duration) error {
+	return mocker.Wait(ctx, time.Duration(delay))
+}
+
+// ToGRPCStatus converts the stub status to a gRPC status. The details are the JSON form of google.protobuf.Any messages,
+// e.g. {"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "..."}; the google.rpc error details types are
+// always resolvable.
+func (s *Status) ToGRPCStatus() (*status.Status, error) {
+	code, err := ParseCode(s.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	st := status.New(code, s.Message).Proto()
+	for i, detail := range s.Details {
+		detailAny := &anypb.Any{}
+		if err = protojson.Unmarshal(detail, detailAny); err != nil {
+			return nil, fmt.Errorf("unmarshal status detail #%d: %w", i, err)
+		}
+		st.Details = append(st.Details, detailAny)
+	}
+	return status.FromProto(st), nil
+}
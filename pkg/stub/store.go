@@ -0,0 +1,338 @@
+package stub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// DefaultPollInterval is the interval a Store polls its directory at when it can't be notified of changes
+const DefaultPollInterval = time.Second
+
+// reloadDebounce is how long a watching Store waits for more changes before reloading, as editing a stub usually changes
+// files more than once
+const reloadDebounce = 100 * time.Millisecond
+
+// Store holds the stubs of a directory, read, validated and parsed once into memory, so requests are matched without
+// touching the disk. While watched (see Watch), the stubs are reloaded whenever the files of the directory change.
+// The stubs are swapped atomically: requests are always matched against a whole set of valid stubs, and a reload which
+// fails (e.g. on an invalid stub file) keeps the previous stubs and is reported.
+type Store struct {
+	dir          string
+	methods      []protoreflect.MethodDescriptor
+	onReload     func(err error)
+	pollInterval time.Duration
+
+	stubs atomic.Pointer[MethodFileStubs]
+
+	// mu serializes the reloads
+	mu          sync.Mutex
+	err         error
+	fingerprint uint64
+}
+
+// StoreOption configures a Store
+type StoreOption func(s *Store)
+
+// WithMethods validates the stubs of the given methods against their descriptors (see Validate) and parses their
+// responses into messages once, instead of on each request
+func WithMethods(mds ...protoreflect.MethodDescriptor) StoreOption {
+	return func(s *Store) {
+		s.methods = append(s.methods, mds...)
+	}
+}
+
+// WithServices is like WithMethods, for all the methods of the given services
+func WithServices(sds ...protoreflect.ServiceDescriptor) StoreOption {
+	return func(s *Store) {
+		for _, sd := range sds {
+			for i := 0; i < sd.Methods().Len(); i++ {
+				s.methods = append(s.methods, sd.Methods().Get(i))
+			}
+		}
+	}
+}
+
+// WithReloadHandler sets the function called after each reload triggered by a change of the stubs directory, with the
+// reload error (nil on success). By default, reloads are logged.
+func WithReloadHandler(fn func(err error)) StoreOption {
+	return func(s *Store) {
+		s.onReload = fn
+	}
+}
+
+// WithPollInterval makes Watch poll the stubs directory at the given interval instead of being notified of changes,
+// for file systems which don't support change notifications (e.g. some container volume mounts)
+func WithPollInterval(interval time.Duration) StoreOption {
+	return func(s *Store) {
+		s.pollInterval = interval
+	}
+}
+
+// NewStore creates a store of the stubs of dir (see MapStubFiles) and loads them
+func NewStore(dir string, opts ...StoreOption) (*Store, error) {
+	s := &Store{dir: dir}
+	s.onReload = s.logReload
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Stubs returns the current stubs of the store. They must not be modified.
+func (s *Store) Stubs() MethodFileStubs {
+	if s == nil {
+		return nil
+	}
+	if stubs := s.stubs.Load(); stubs != nil {
+		return *stubs
+	}
+	return nil
+}
+
+// Err returns the error of the last reload, or nil if it succeeded
+func (s *Store) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Reload reads the stubs directory again, and swaps the stubs of the store with the read stubs if they're valid.
+// Otherwise, the previous stubs are kept and the error is returned.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fingerprint, _ = dirFingerprint(s.dir)
+	stubs, err := s.load()
+	s.err = err
+	if err != nil {
+		return err
+	}
+	s.stubs.Store(&stubs)
+	return nil
+}
+
+func (s *Store) load() (MethodFileStubs, error) {
+	stubs, err := MapStubFiles(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("map stub files: %w", err)
+	}
+
+	for key, methodStubs := range stubs {
+		for i := range methodStubs {
+			if err = methodStubs[i].load(s.methodOf(key, methodStubs[i])); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err = Validate(stubs, s.methods...); err != nil {
+		return nil, fmt.Errorf("validate stub files: %w", err)
+	}
+	return stubs, nil
+}
+
+// methodOf returns the descriptor of the method of the stub, or nil if it's unknown
+func (s *Store) methodOf(key string, stub MethodFileStub) protoreflect.MethodDescriptor {
+	for _, md := range s.methods {
+		if string(md.Name()) == key && stub.isOf(md) {
+			return md
+		}
+	}
+	return nil
+}
+
+func (s *Store) logReload(err error) {
+	if err != nil {
+		log.Printf("Failed reloading the stubs of %q, keeping the previous stubs: %v\n", s.dir, err)
+		return
+	}
+	log.Printf("Reloaded the stubs of %q\n", s.dir)
+}
+
+// Watch reloads the stubs whenever the files of the stubs directory change, until ctx is done. The directory is watched
+// with file system notifications, falling back to polling it every DefaultPollInterval when they're not available (or
+// polling at the interval set with WithPollInterval).
+func (s *Store) Watch(ctx context.Context) {
+	if s.pollInterval > 0 {
+		s.poll(ctx, s.pollInterval)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Failed watching the stubs of %q, polling them instead: %v\n", s.dir, err)
+		s.poll(ctx, DefaultPollInterval)
+		return
+	}
+	defer watcher.Close()
+	if err = watchDirs(watcher, s.dir); err != nil {
+		log.Printf("Failed watching the stubs of %q, polling them instead: %v\n", s.dir, err)
+		s.poll(ctx, DefaultPollInterval)
+		return
+	}
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) {
+				// New directories have to be watched too
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					_ = watchDirs(watcher, event.Name)
+				}
+			}
+			debounce.Reset(reloadDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error watching the stubs of %q: %v\n", s.dir, err)
+		case <-debounce.C:
+			s.onReload(s.Reload())
+		}
+	}
+}
+
+func (s *Store) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A directory which can't be read is fingerprinted as far as it can, to report its errors once
+			fingerprint, _ := dirFingerprint(s.dir)
+			s.mu.Lock()
+			changed := fingerprint != s.fingerprint
+			s.mu.Unlock()
+			if changed {
+				s.onReload(s.Reload())
+			}
+		}
+	}
+}
+
+func watchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// dirFingerprint hashes the paths, sizes and modification times of the files of a directory, to detect changes
+func dirFingerprint(root string) (uint64, error) {
+	h := fnv.New64a()
+	err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+		return err
+	})
+	return h.Sum64(), err
+}
+
+// loadedStub is the content of a stub read and parsed once by a Store
+type loadedStub struct {
+	request []byte
+	// requestValue is the decoded request JSON, if it's valid
+	requestValue   any
+	hasValue       bool
+	sequence       []json.RawMessage
+	sequenceValues []any
+	// responseFile is the content of the response file of request/response pairs
+	responseFile []byte
+
+	// output is the descriptor the response was parsed with, if the method of the stub is known
+	output       protoreflect.MessageDescriptor
+	responseJSON []byte
+	response     *Response
+	// responseMessage is the parsed response message, unless the response has templates to render
+	responseMessage proto.Message
+}
+
+// load reads the stub files into memory and parses them, with the descriptor of the stub method if it's known
+func (s *MethodFileStub) load(md protoreflect.MethodDescriptor) error {
+	loaded := &loadedStub{}
+	var err error
+	if loaded.request, err = s.RequestJSON(); err != nil {
+		return err
+	}
+	if loaded.sequence, err = s.RequestSequence(); err != nil {
+		return err
+	}
+	if loaded.sequence == nil {
+		loaded.hasValue = json.Unmarshal(loaded.request, &loaded.requestValue) == nil
+	} else {
+		loaded.hasValue = true
+		for _, reqJSON := range loaded.sequence {
+			var value any
+			if json.Unmarshal(reqJSON, &value) != nil {
+				loaded.hasValue = false
+				break
+			}
+			loaded.sequenceValues = append(loaded.sequenceValues, value)
+		}
+	}
+	if s.Case == nil {
+		if loaded.responseFile, err = os.ReadFile(s.ResponseFilePath); err != nil {
+			return fmt.Errorf("read stub response %q: %w", s.ResponseFilePath, err)
+		}
+	}
+	s.loaded = loaded
+
+	if md == nil {
+		return nil
+	}
+	// Invalid responses are left to be reported by the validation
+	responseJSON, response, err := s.Response(md.Output())
+	if err != nil {
+		return nil
+	}
+	loaded.output = md.Output()
+	loaded.responseJSON = responseJSON
+	loaded.response = response
+	hasTemplate := isTemplate(responseJSON)
+	for _, streamMessage := range response.Messages {
+		hasTemplate = hasTemplate || isTemplate(streamMessage.Message)
+	}
+	if !hasTemplate {
+		msg := mocker.NewMessage(md.Output())
+		if protojson.Unmarshal(responseJSON, msg) == nil {
+			loaded.responseMessage = msg
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package stub
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,20 +10,53 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"github.com/nsf/jsondiff"
 	"github.com/oriser/regroup"
+	"github.com/torqio/grpcmock/pkg/mocker"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 type MethodFileStub struct {
 	RequestFilePath  string
 	ResponseFilePath string
+
+	// CasesFilePath and Case are set instead of the request and response files for stubs loaded from a multi-case stub
+	// file (see CasesFile)
+	CasesFilePath string
+	Case          *Case
+	// CaseIndex is the index of Case in its file
+	CaseIndex int
+	// Method is the method of the stub as written in its multi-case stub file, which may be a full gRPC method name
+	Method string
+
+	// loaded is set for stubs loaded by a Store, which are read and parsed once
+	loaded *loadedStub
 }
+
+// MethodFileStubs are the stubs keyed by the short name of their method. The lookups (e.g. FindStubResponse) take
+// the full gRPC method name (see mocker.MethodName), so the stubs given the full name of a method of another service
+// aren't matched. A short method name matches the stubs of the methods with that name in every service.
 type MethodFileStubs map[string][]MethodFileStub
 
+// ErrNoMatchingStub is returned when there is no stub matching a request of a method
+type ErrNoMatchingStub struct {
+	Method string
+	// Mismatches describe why each stub of the method didn't match the request
+	Mismatches []string
+}
+
+func (e ErrNoMatchingStub) Error() string {
+	msg := fmt.Sprintf("no matching stub found for method %v with the provided request", mocker.MethodShortName(e.Method))
+	if len(e.Mismatches) == 0 {
+		return msg
+	}
+	return msg + ":\n\t" + strings.Join(e.Mismatches, "\n\t")
+}
+
 type fileMethodRegexpGroup struct {
 	Method string `regroup:"method"`
 }
@@ -43,6 +78,9 @@ func MapStubFiles(rootStubsDir string) (MethodFileStubs, error) {
 	stubFiles := make(MethodFileStubs)
 
 	return stubFiles, filepath.Walk(rootStubsDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if info.IsDir() {
 			return nil
 		}
@@ -50,12 +88,29 @@ func MapStubFiles(rootStubsDir string) (MethodFileStubs, error) {
 		dirName := filepath.Dir(path)
 		fileName := filepath.Base(path)
 
+		if isCasesFile(fileName) {
+			casesFile, err := LoadCasesFile(path)
+			if err != nil {
+				return err
+			}
+			key := methodKey(casesFile.Method)
+			for i := range casesFile.Cases {
+				stubFiles[key] = append(stubFiles[key], MethodFileStub{
+					CasesFilePath: path,
+					Case:          &casesFile.Cases[i],
+					CaseIndex:     i,
+					Method:        casesFile.Method,
+				})
+			}
+			return nil
+		}
+
 		if strings.HasSuffix(fileName, responseSuffix) {
 			// Skipping response files without logging
 			return nil
 		}
 		if !strings.HasSuffix(fileName, requestSuffix) {
-			log.Printf("Skipping file %q as it doesn't have %q suffix\n", path, requestSuffix)
+			log.Printf("Skipping file %q as it doesn't have %q suffix, nor one of the stub files suffixes %v\n", path, requestSuffix, casesFileSuffixes)
 			return nil
 		}
 
@@ -85,47 +140,407 @@ func MapStubFiles(rootStubsDir string) (MethodFileStubs, error) {
 	})
 }
 
+// String describes the stub source for errors and logs
+func (s MethodFileStub) String() string {
+	if s.Case != nil {
+		return fmt.Sprintf("%q case %s", s.CasesFilePath, caseName(s.CaseIndex, *s.Case))
+	}
+	return fmt.Sprintf("%q", s.RequestFilePath)
+}
+
+// RequestJSON returns the JSON the requests are matched against
+func (s MethodFileStub) RequestJSON() ([]byte, error) {
+	if s.loaded != nil {
+		return s.loaded.request, nil
+	}
+	if s.Case != nil {
+		if len(s.Case.Request) == 0 {
+			return []byte("{}"), nil
+		}
+		return s.Case.Request, nil
+	}
+
+	b, err := os.ReadFile(s.RequestFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("read stub request %q: %w", s.RequestFilePath, err)
+	}
+	return b, nil
+}
+
+// Priority returns the priority of the stub. Stubs of higher priority are matched first, and stubs of the same
+// priority are matched in order.
+func (s MethodFileStub) Priority() int {
+	if s.Case == nil {
+		return 0
+	}
+	return s.Case.Priority
+}
+
+// scenarioMismatch returns why the stub doesn't match in the current state of its scenario (see Case.Scenario), or an
+// empty string if it does. Without a mocker in ctx, scenarios are in mocker.ScenarioStarted.
+func (s MethodFileStub) scenarioMismatch(ctx context.Context) string {
+	if s.Case == nil || s.Case.State == "" {
+		return ""
+	}
+	state := mocker.ScenarioStarted
+	if m := mocker.FromContext(ctx); m != nil {
+		state = m.ScenarioState(s.Case.Scenario)
+	}
+	if state == s.Case.State {
+		return ""
+	}
+	return fmt.Sprintf("scenario %q is in state %q, not %q", s.Case.Scenario, state, s.Case.State)
+}
+
+// transitionScenario transitions the scenario of the matched stub, if it has a transition, and reports whether its
+// scenario was still in the state of the stub, so concurrent calls don't all match a stub of a one-shot state
+func (s MethodFileStub) transitionScenario(ctx context.Context) bool {
+	if s.Case == nil {
+		return true
+	}
+	if m := mocker.FromContext(ctx); m != nil {
+		return m.CompareAndTransition(s.Case.Scenario, s.Case.State, s.Case.TransitionTo)
+	}
+	return true
+}
+
+// RequestSequence returns the sequence of client stream messages the stub expects, or nil if the stub matches single
+// requests. In request files, a sequence is written as a JSON array.
+func (s MethodFileStub) RequestSequence() ([]json.RawMessage, error) {
+	if s.loaded != nil {
+		return s.loaded.sequence, nil
+	}
+	if s.Case != nil {
+		return s.Case.Requests, nil
+	}
+
+	b, err := s.RequestJSON()
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, nil
+	}
+
+	var sequence []json.RawMessage
+	if err = json.Unmarshal(b, &sequence); err != nil {
+		return nil, fmt.Errorf("parse stub requests sequence %q: %w", s.RequestFilePath, err)
+	}
+	if sequence == nil {
+		sequence = []json.RawMessage{}
+	}
+	return sequence, nil
+}
+
+// Response returns the JSON of the response message, of the given descriptor, and the rest of the stub response.
+// A response file may contain the response message alone, or an envelope with the message under "response" along with
+// "messages", "status", "headers", "trailers" and "delay" keys (like a Case).
+func (s MethodFileStub) Response(md protoreflect.MessageDescriptor) ([]byte, *Response, error) {
+	if s.loaded != nil && s.loaded.output != nil && s.loaded.output.FullName() == md.FullName() {
+		return s.loaded.responseJSON, s.loaded.response, nil
+	}
+	if s.Case != nil {
+		response := &Response{
+			Status:   s.Case.Status,
+			Headers:  s.Case.Headers,
+			Trailers: s.Case.Trailers,
+			Delay:    s.Case.Delay,
+			Messages: s.Case.Messages,
+		}
+		if len(s.Case.Response) == 0 {
+			return []byte("{}"), response, nil
+		}
+		return s.Case.Response, response, nil
+	}
+
+	b, err := s.responseFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, response, err := parseResponseFile(b, md)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stub response %q: %w", s.ResponseFilePath, err)
+	}
+	return msg, response, nil
+}
+
+func (s MethodFileStub) responseFile() ([]byte, error) {
+	if s.loaded != nil {
+		return s.loaded.responseFile, nil
+	}
+	b, err := os.ReadFile(s.ResponseFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("read stub response %q: %w", s.ResponseFilePath, err)
+	}
+	return b, nil
+}
+
+// GetFileStubResponse fills res with the response message of the stub matching req. If the matching stub responds with
+// an error status, the status error is returned.
 func GetFileStubResponse(stubs MethodFileStubs, method string, req proto.Message, res proto.Message) error {
-	stubFiles := stubs[method]
-	if len(stubFiles) == 0 {
-		return fmt.Errorf("not stubs for %q", method)
+	response, err := getStubResponse(context.Background(), stubs, method, req, res)
+	if err != nil {
+		return err
 	}
+	return response.Err()
+}
+
+// FindFileStubResponse fills res with the response of the stub matching req, and returns whether a matching stub was
+// found. Unlike GetFileStubResponse, not finding a matching stub (or having no stubs at all) is not an error.
+// The error status, metadata and delay of the stub are ignored, use FindStubResponse to get them.
+func FindFileStubResponse(stubs MethodFileStubs, method string, req proto.Message, res proto.Message) (bool, error) {
+	response, err := FindStubResponse(context.Background(), stubs, method, req, res)
+	return response != nil, err
+}
 
+// FindStubResponse fills res with the response message of the stub matching req, and returns the rest of the stub
+// response (see Response.Respond), or nil if no stub matches.
+// Templated responses (see TemplateData) are rendered with the request and the metadata of ctx.
+func FindStubResponse(ctx context.Context, stubs MethodFileStubs, method string, req proto.Message, res proto.Message) (*Response, error) {
+	if stubs == nil {
+		return nil, nil
+	}
+
+	response, err := getStubResponse(ctx, stubs, method, req, res)
+	if errors.As(err, &ErrNoMatchingStub{}) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// HasStreamStubs returns whether the method has stubs with a requests sequence, which are matched only once a client
+// stream is closed
+func HasStreamStubs(stubs MethodFileStubs, method string) bool {
+	for _, stubFile := range methodStubs(stubs, method) {
+		if sequence, err := stubFile.RequestSequence(); err == nil && sequence != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// FindStreamStubResponse is like FindStubResponse, for the whole sequence of messages received on a client stream.
+// Only stubs with a requests sequence (see MethodFileStub.RequestSequence) are matched.
+func FindStreamStubResponse(ctx context.Context, stubs MethodFileStubs, method string, reqs []proto.Message, res proto.Message) (*Response, error) {
+	if stubs == nil {
+		return nil, nil
+	}
+
+	gotJSONs := make([][]byte, 0, len(reqs))
+	for _, req := range reqs {
+		gotJSON, err := protojson.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request JSON: %w", err)
+		}
+		gotJSONs = append(gotJSONs, gotJSON)
+	}
+
+	response, err := findStub(ctx, stubs, method, gotJSONs, res, streamMatcher(gotJSONs))
+	if errors.As(err, &ErrNoMatchingStub{}) {
+		return nil, nil
+	}
+	return response, err
+}
+
+// ExplainNoMatch returns an ErrNoMatchingStub describing why each stub of the method doesn't match req, or nil if the
+// method has no stubs or a stub matches. It's meant to enrich the errors of requests nothing responded to.
+func ExplainNoMatch(ctx context.Context, stubs MethodFileStubs, method string, req proto.Message) error {
 	gotJSON, err := protojson.Marshal(req)
 	if err != nil {
-		return fmt.Errorf("marshal request JSON: %w", err)
+		return nil
 	}
+	return explainNoMatch(ctx, stubs, method, requestMatcher(ctx, req, gotJSON))
+}
 
-	for _, stubFile := range stubFiles {
-		stubReqJSON, err := os.ReadFile(stubFile.RequestFilePath)
+// ExplainStreamNoMatch is like ExplainNoMatch, for the whole sequence of messages received on a client stream
+func ExplainStreamNoMatch(ctx context.Context, stubs MethodFileStubs, method string, reqs []proto.Message) error {
+	gotJSONs := make([][]byte, 0, len(reqs))
+	for _, req := range reqs {
+		gotJSON, err := protojson.Marshal(req)
 		if err != nil {
-			return fmt.Errorf("read stub request %q: %w", stubFile.RequestFilePath, err)
+			return nil
 		}
+		gotJSONs = append(gotJSONs, gotJSON)
+	}
+	return explainNoMatch(ctx, stubs, method, streamMatcher(gotJSONs))
+}
 
-		if !json.Valid(stubReqJSON) {
-			return fmt.Errorf("stub file %q contains an invalid JSON", stubFile.RequestFilePath)
+func explainNoMatch(ctx context.Context, stubs MethodFileStubs, method string, matches stubMatcher) error {
+	if len(methodStubs(stubs, method)) == 0 {
+		return nil
+	}
+	stubFile, mismatches, err := matchStub(ctx, stubs, method, matches)
+	if err != nil || stubFile != nil {
+		return nil
+	}
+	return ErrNoMatchingStub{Method: method, Mismatches: mismatches}
+}
+
+func getStubResponse(ctx context.Context, stubs MethodFileStubs, method string, req proto.Message, res proto.Message) (*Response, error) {
+	gotJSON, err := protojson.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request JSON: %w", err)
+	}
+
+	return findStub(ctx, stubs, method, [][]byte{gotJSON}, res, requestMatcher(ctx, req, gotJSON))
+}
+
+// stubMatcher returns why a stub doesn't match, or an empty string if it matches
+type stubMatcher func(stubFile MethodFileStub) (string, error)
+
+// requestMatcher matches the stubs of single requests against req, whose JSON form is gotJSON
+func requestMatcher(ctx context.Context, req proto.Message, gotJSON []byte) stubMatcher {
+	var gotValue any
+	decodeErr := json.Unmarshal(gotJSON, &gotValue)
+	return func(stubFile MethodFileStub) (string, error) {
+		if decodeErr != nil {
+			return "", fmt.Errorf("unmarshal request JSON: %w", decodeErr)
+		}
+		sequence, err := stubFile.RequestSequence()
+		if err != nil {
+			return "", err
+		}
+		if sequence != nil {
+			return "matches only client streams", nil
 		}
 
-		o := jsondiff.DefaultJSONOptions()
-		compareRes, _ := jsondiff.Compare(gotJSON, stubReqJSON, &o)
-		if compareRes != jsondiff.FullMatch && compareRes != jsondiff.SupersetMatch {
-			// We consider a request as a match in case it's a full match (JSON is identical) or it's a superset -
-			// means the stub is a subset of the request JSON
-			// (because we may have fields which we don't want to compare like date fields)
-			continue
+		return stubFile.matchRequest(ctx, req, gotValue)
+	}
+}
+
+// streamMatcher matches the stubs of requests sequences against the messages of a client stream
+func streamMatcher(gotJSONs [][]byte) stubMatcher {
+	gotValues := make([]any, len(gotJSONs))
+	var decodeErr error
+	for i, gotJSON := range gotJSONs {
+		if err := json.Unmarshal(gotJSON, &gotValues[i]); err != nil {
+			decodeErr = err
+		}
+	}
+	return func(stubFile MethodFileStub) (string, error) {
+		if decodeErr != nil {
+			return "", fmt.Errorf("unmarshal request JSON: %w", decodeErr)
 		}
+		sequence, err := stubFile.RequestSequence()
+		if err != nil {
+			return "", err
+		}
+		if sequence == nil {
+			return "matches only single requests", nil
+		}
+		return stubFile.matchSequence(gotValues)
+	}
+}
+
+// methodStubs returns the stubs of method, a short or full gRPC method name. Stubs of methods with the same name in
+// other services are left out, if both names are full.
+func methodStubs(stubs MethodFileStubs, method string) []MethodFileStub {
+	var filtered []MethodFileStub
+	for _, stubFile := range stubs[methodKey(method)] {
+		if stubFile.isOfMethod(method) {
+			filtered = append(filtered, stubFile)
+		}
+	}
+	return filtered
+}
+
+// matchStub returns the first stub of method that matches, by descending priority and then in order, along with why
+// the stubs before it didn't match
+func matchStub(ctx context.Context, stubs MethodFileStubs, method string, matches stubMatcher) (*MethodFileStub, []string, error) {
+	stubFiles := methodStubs(stubs, method)
+	sort.SliceStable(stubFiles, func(i, j int) bool {
+		return stubFiles[i].Priority() > stubFiles[j].Priority()
+	})
 
-		stubResponseJSON, err := os.ReadFile(stubFile.ResponseFilePath)
+	var mismatches []string
+	for i, stubFile := range stubFiles {
+		if reason := stubFile.scenarioMismatch(ctx); reason != "" {
+			mismatches = append(mismatches, fmt.Sprintf("stub %v: %s", stubFile, reason))
+			continue
+		}
+		reason, err := matches(stubFile)
 		if err != nil {
-			return fmt.Errorf("read stub response %q: %w", stubFile.ResponseFilePath, err)
+			return nil, nil, err
+		}
+		if reason == "" {
+			return &stubFiles[i], mismatches, nil
 		}
+		mismatches = append(mismatches, fmt.Sprintf("stub %v: %s", stubFile, reason))
+	}
+	return nil, mismatches, nil
+}
 
-		if err := protojson.Unmarshal(stubResponseJSON, res); err != nil {
-			return fmt.Errorf("unmarshal stub response into provided response type: %w", err)
+// findStub fills res with the response message of the first stub of method that matches (see matchStub), and returns
+// the rest of its response. Templated responses are rendered with requestsJSON.
+func findStub(ctx context.Context, stubs MethodFileStubs, method string, requestsJSON [][]byte, res proto.Message, matches stubMatcher) (*Response, error) {
+	var stubFile *MethodFileStub
+	for {
+		matched, mismatches, err := matchStub(ctx, stubs, method, matches)
+		if err != nil {
+			return nil, err
+		}
+		if matched == nil {
+			return nil, ErrNoMatchingStub{Method: method, Mismatches: mismatches}
 		}
+		// The scenario may have transitioned by a concurrent call since matchStub, then matching again in its new state
+		if matched.transitionScenario(ctx) {
+			stubFile = matched
+			break
+		}
+	}
 
-		return nil
+	if loaded := stubFile.loaded; loaded != nil && loaded.responseMessage != nil && loaded.responseMessage.ProtoReflect().Type() == res.ProtoReflect().Type() {
+		proto.Merge(res, loaded.responseMessage)
+		return loaded.response, nil
+	}
+
+	stubResponseJSON, response, err := stubFile.Response(res.ProtoReflect().Descriptor())
+	if err != nil {
+		return nil, err
+	}
+	if stubResponseJSON, response, err = renderResponse(ctx, *stubFile, stubResponseJSON, response, requestsJSON); err != nil {
+		return nil, err
 	}
 
-	return fmt.Errorf("no matching stub found for the provided request")
+	if err := protojson.Unmarshal(stubResponseJSON, res); err != nil {
+		return nil, fmt.Errorf("unmarshal stub response into provided response type: %w", err)
+	}
+	return response, nil
+}
+
+// renderResponse renders the response message and stream messages of a stub which are templates
+func renderResponse(ctx context.Context, stubFile MethodFileStub, msg []byte, response *Response, requestsJSON [][]byte) ([]byte, *Response, error) {
+	hasTemplate := isTemplate(msg)
+	for _, streamMessage := range response.Messages {
+		hasTemplate = hasTemplate || isTemplate(streamMessage.Message)
+	}
+	if !hasTemplate {
+		return msg, response, nil
+	}
+
+	data, err := newTemplateData(ctx, requestsJSON...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if msg, err = renderTemplate(stubFile.String(), msg, data); err != nil {
+		return nil, nil, fmt.Errorf("stub %v: %w", stubFile, err)
+	}
+
+	// Copying the response, as the messages of cases are shared between calls
+	rendered := *response
+	rendered.Messages = make([]StreamMessage, len(response.Messages))
+	for i, streamMessage := range response.Messages {
+		rendered.Messages[i] = streamMessage
+		if rendered.Messages[i].Message, err = renderTemplate(stubFile.String(), streamMessage.Message, data); err != nil {
+			return nil, nil, fmt.Errorf("stub %v stream message #%d: %w", stubFile, i, err)
+		}
+	}
+	if response.Messages == nil {
+		rendered.Messages = nil
+	}
+	return msg, &rendered, nil
 }
@@ -0,0 +1,121 @@
+package stub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/google/uuid"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc/metadata"
+)
+
+// TemplateData is the data stub response templates are rendered with.
+// A stub response (message or stream message) is a template if it contains "{{", e.g.
+//
+//	{"id": {{ json .Request.id }}, "owner": {{ json .Metadata.user }}, "requestId": "{{ uuid }}", "createdAt": "{{ now }}"}
+//
+// Templates are Go text/template templates with the sprig functions, where `json` returns the JSON form of a value
+// (e.g. a quoted and escaped string), `now` returns the current time formatted as RFC 3339 (the JSON form of
+// google.protobuf.Timestamp), `nowTime` returns it as a time.Time (for sprig date functions) and `uuid` returns a random
+// UUID. The current time is told by the clock of the mocker (see mocker.Clock).
+// Values are rendered as is, so request and metadata values should be rendered with `json` rather than within quotes,
+// e.g. {"res": {{ printf "echo %s" .Request.req | json }}}.
+type TemplateData struct {
+	// Request is the JSON form of the request, keyed by the JSON field names (lowerCamelCase).
+	// For requests sequences, it is the last message of the client stream.
+	Request map[string]any
+	// Requests are the JSON forms of all the messages of the client stream, for requests sequences
+	Requests []map[string]any
+	// Metadata is the request metadata, with the first value of each key
+	Metadata map[string]string
+
+	// clock tells the time to the `now` functions
+	clock mocker.Clock
+}
+
+var templateFuncs = func() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	for name, fn := range clockFuncs(mocker.ClockFromContext(context.Background())) {
+		funcs[name] = fn
+	}
+	funcs["uuid"] = uuid.NewString
+	funcs["json"] = jsonValue
+	return funcs
+}()
+
+// jsonValue returns the JSON form of v, to render values which may hold quotes, backslashes, maps or lists
+func jsonValue(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// clockFuncs returns the template functions telling the time of clock
+func clockFuncs(clock mocker.Clock) template.FuncMap {
+	return template.FuncMap{
+		"now": func() string {
+			return clock.Now().UTC().Format(time.RFC3339Nano)
+		},
+		"nowTime": clock.Now,
+	}
+}
+
+// newTemplateData creates the template data of the given requests JSON forms
+func newTemplateData(ctx context.Context, requestsJSON ...[]byte) (*TemplateData, error) {
+	data := &TemplateData{Metadata: map[string]string{}, clock: mocker.ClockFromContext(ctx)}
+	for i, requestJSON := range requestsJSON {
+		request := map[string]any{}
+		if err := json.Unmarshal(requestJSON, &request); err != nil {
+			return nil, fmt.Errorf("unmarshal request #%d JSON: %w", i, err)
+		}
+		data.Requests = append(data.Requests, request)
+		data.Request = request
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if len(values) > 0 {
+			data.Metadata[key] = values[0]
+		}
+	}
+	return data, nil
+}
+
+func isTemplate(b []byte) bool {
+	return bytes.Contains(b, []byte("{{"))
+}
+
+func parseTemplate(name string, b []byte) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parse response template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderTemplate renders b with data if it's a template, and returns it as is otherwise
+func renderTemplate(name string, b []byte, data *TemplateData) ([]byte, error) {
+	if !isTemplate(b) {
+		return b, nil
+	}
+
+	tmpl, err := parseTemplate(name, b)
+	if err != nil {
+		return nil, err
+	}
+	if data.clock != nil {
+		tmpl.Funcs(clockFuncs(data.clock))
+	}
+	rendered := &bytes.Buffer{}
+	if err = tmpl.Execute(rendered, data); err != nil {
+		return nil, fmt.Errorf("render response template: %w", err)
+	}
+	return rendered.Bytes(), nil
+}
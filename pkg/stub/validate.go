@@ -0,0 +1,271 @@
+package stub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ValidateService validates the stubs of all the methods of the given service (see Validate)
+func ValidateService(stubs MethodFileStubs, sd protoreflect.ServiceDescriptor) error {
+	methods := sd.Methods()
+	mds := make([]protoreflect.MethodDescriptor, 0, methods.Len())
+	for i := 0; i < methods.Len(); i++ {
+		mds = append(mds, methods.Get(i))
+	}
+	return Validate(stubs, mds...)
+}
+
+// Validate validates the stubs of the given methods against their descriptors: the requests and responses must be
+// valid JSON forms of the method input and output messages, and the statuses must have valid code names and details.
+// Requests sequences and stream messages are allowed only for client and server streaming methods respectively.
+// Templated responses are only checked to be valid templates, and the regexes, JSONPath and CEL expressions requests
+// are matched with must compile.
+// Stubs of other methods are not validated, as they may belong to other services sharing the stubs directory.
+// All the invalid stubs are reported in the returned error.
+func Validate(stubs MethodFileStubs, methods ...protoreflect.MethodDescriptor) error {
+	var errs []error
+	for _, md := range methods {
+		for _, stub := range stubs[string(md.Name())] {
+			if !stub.isOf(md) {
+				continue
+			}
+			if err := validateStub(md, stub); err != nil {
+				errs = append(errs, fmt.Errorf("stub %v of %v: %w", stub, md.FullName(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isOf returns whether the stub may be of the given method (see isOfMethod)
+func (s MethodFileStub) isOf(md protoreflect.MethodDescriptor) bool {
+	return s.isOfMethod(mocker.MethodName(md))
+}
+
+// isOfMethod returns whether the stub may be of method, a short or full gRPC method name. Stubs are keyed by the method
+// short name, so only stubs which were given a full gRPC method name can be told apart from stubs of methods with the
+// same name in other services.
+func (s MethodFileStub) isOfMethod(method string) bool {
+	if !strings.HasPrefix(s.Method, "/") || !strings.HasPrefix(method, "/") {
+		return true
+	}
+	return s.Method == method
+}
+
+func validateStub(md protoreflect.MethodDescriptor, stub MethodFileStub) error {
+	sequence, err := stub.RequestSequence()
+	if err != nil {
+		return err
+	}
+	if sequence != nil {
+		if !md.IsStreamingClient() {
+			return fmt.Errorf("a requests sequence can only be matched by client streaming methods")
+		}
+		for i, reqJSON := range sequence {
+			if err = validateRequest(md.Input(), reqJSON); err != nil {
+				return fmt.Errorf("invalid request #%d for %v: %w", i, md.Input().FullName(), err)
+			}
+		}
+	} else {
+		reqJSON, err := stub.RequestJSON()
+		if err != nil {
+			return err
+		}
+		if err = validateRequest(md.Input(), reqJSON); err != nil {
+			return fmt.Errorf("invalid request for %v: %w", md.Input().FullName(), err)
+		}
+	}
+
+	if err = validateMatching(md, stub); err != nil {
+		return err
+	}
+
+	resJSON, response, err := stub.Response(md.Output())
+	if err != nil {
+		return err
+	}
+	if err = validateResponseMessage(md.Output(), resJSON); err != nil {
+		return fmt.Errorf("invalid response for %v: %w", md.Output().FullName(), err)
+	}
+
+	if response.Messages != nil && !md.IsStreamingServer() {
+		return fmt.Errorf("stream messages can only be sent by server streaming methods")
+	}
+	for i, streamMessage := range response.Messages {
+		if err = validateResponseMessage(md.Output(), streamMessage.Message); err != nil {
+			return fmt.Errorf("invalid stream message #%d for %v: %w", i, md.Output().FullName(), err)
+		}
+	}
+
+	if response.Status != nil {
+		if _, err = response.Status.ToGRPCStatus(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRequest validates a stub request JSON, without the values which are placeholders or regexes as they don't
+// have to be of the type of their field
+func validateRequest(md protoreflect.MessageDescriptor, b []byte) error {
+	var req any
+	if err := json.Unmarshal(b, &req); err != nil {
+		return err
+	}
+	b, err := json.Marshal(withoutPlaceholders(req))
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(b, dynamicpb.NewMessage(md))
+}
+
+func withoutPlaceholders(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		fields := make(map[string]any, len(value))
+		for key, fieldValue := range value {
+			if !isPlaceholder(fieldValue) {
+				fields[key] = withoutPlaceholders(fieldValue)
+			}
+		}
+		return fields
+	case []any:
+		elements := make([]any, 0, len(value))
+		for _, element := range value {
+			if !isPlaceholder(element) {
+				elements = append(elements, withoutPlaceholders(element))
+			}
+		}
+		return elements
+	}
+	return v
+}
+
+func isPlaceholder(v any) bool {
+	s, ok := v.(string)
+	return ok && (s == AnyPlaceholder || s == AbsentPlaceholder || strings.HasPrefix(s, RegexPrefix))
+}
+
+// validateMatching validates the regexes, JSONPath expressions and CEL expression the stub matches requests with
+func validateMatching(md protoreflect.MethodDescriptor, stub MethodFileStub) error {
+	var requestsJSON []json.RawMessage
+	if sequence, err := stub.RequestSequence(); err != nil {
+		return err
+	} else if sequence != nil {
+		requestsJSON = sequence
+	} else {
+		reqJSON, err := stub.RequestJSON()
+		if err != nil {
+			return err
+		}
+		requestsJSON = []json.RawMessage{reqJSON}
+	}
+	for _, reqJSON := range requestsJSON {
+		var req any
+		if err := json.Unmarshal(reqJSON, &req); err != nil {
+			return fmt.Errorf("parse request: %w", err)
+		}
+		if err := validateRegexes(req); err != nil {
+			return err
+		}
+	}
+
+	if stub.Case == nil {
+		return nil
+	}
+	for expr, value := range stub.Case.JSONPath {
+		if _, err := compileJSONPath(expr); err != nil {
+			return err
+		}
+		if err := validateRegexes(value); err != nil {
+			return err
+		}
+	}
+	if stub.Case.CEL != "" {
+		if _, err := compileCEL(md.Input(), stub.Case.CEL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRegexes compiles the regexes (see RegexPrefix) of a stub request JSON value
+func validateRegexes(v any) error {
+	switch value := v.(type) {
+	case string:
+		if strings.HasPrefix(value, RegexPrefix) {
+			_, err := compileRegex(strings.TrimPrefix(value, RegexPrefix))
+			return err
+		}
+	case map[string]any:
+		for _, fieldValue := range value {
+			if err := validateRegexes(fieldValue); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, element := range value {
+			if err := validateRegexes(element); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateResponseMessage validates a response message JSON. Templates are only parsed, as their JSON is known only once
+// they're rendered with a request.
+func validateResponseMessage(md protoreflect.MessageDescriptor, b []byte) error {
+	if isTemplate(b) {
+		_, err := parseTemplate("response", b)
+		return err
+	}
+	return protojson.Unmarshal(b, dynamicpb.NewMessage(md))
+}
+
+// ParseCode parses a status code name (e.g. NOT_FOUND), as written in stub files
+func ParseCode(name string) (codes.Code, error) {
+	var code codes.Code
+	if err := code.UnmarshalJSON([]byte(strconv.Quote(name))); err != nil {
+		return 0, fmt.Errorf("invalid status code %q", name)
+	}
+	return code, nil
+}
+
+// codeNames are the names of the status codes as written in stub files
+var codeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+// CodeName returns the name of a status code as written in stub files (e.g. NOT_FOUND), the inverse of ParseCode
+func CodeName(code codes.Code) string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
@@ -1,18 +0,0 @@
-module github.com/torqio/grpcmock/protoc-gen-grpcmock
-
-go 1.22
-
-require (
-	github.com/pschlump/sprig v2.15.0+incompatible
-	google.golang.org/protobuf v1.35.2
-)
-
-require (
-	github.com/Masterminds/semver v1.5.0 // indirect
-	github.com/aokoli/goutils v1.1.1 // indirect
-	github.com/google/uuid v1.3.0 // indirect
-	github.com/huandu/xstrings v1.3.2 // indirect
-	github.com/imdario/mergo v0.3.12 // indirect
-	github.com/stretchr/testify v1.7.1 // indirect
-	golang.org/x/crypto v0.17.0 // indirect
-)
@@ -10,13 +10,17 @@ import (
 	"path"
 	"text/template"
 
-	"github.com/pschlump/sprig"
+	"github.com/Masterminds/sprig/v3"
+	"github.com/torqio/grpcmock/pkg/crudfake"
 	"google.golang.org/protobuf/compiler/protogen"
 )
 
 //go:embed server_tmpl/mock_server.tmpl
 var MockServerTemplate string
 
+//go:embed server_tmpl/remote_mock.tmpl
+var RemoteMockTemplate string
+
 //go:embed cmd_tmpl/server.tmpl
 var CmdServerTemplate string
 
@@ -29,6 +33,18 @@ var CmdDockerfileTemplate string
 //go:embed cmd_tmpl/registry.tmpl
 var RegistryTemplate string
 
+// mockFileData is the template data of the generated mock files
+type mockFileData struct {
+	*protogen.File
+	// CRUDFakes is whether to generate in-memory CRUD fakes of the services with standard methods
+	CRUDFakes bool
+}
+
+// hasStandardMethods returns whether the service has standard methods, which the CRUD fake of the service serves
+func hasStandardMethods(svc *protogen.Service) bool {
+	return crudfake.HasStandardMethods(svc.Desc)
+}
+
 func log(msg string, args ...interface{}) {
 	os.Stderr.WriteString(fmt.Sprintf(msg+"\n", args...))
 }
@@ -68,6 +84,7 @@ func generateFileAndExecuteTemplate(plugin *protogen.Plugin, goImportPath protog
 		"isStreamingClient":    isStreamingClient,
 		"isStreamingServer":    isStreamingServer,
 		"isStreaming":          isStreaming,
+		"hasStandardMethods":   hasStandardMethods,
 	}
 
 	var finalTemplate *template.Template
@@ -135,7 +152,7 @@ func generateCmds(plugin *protogen.Plugin, cmdsPath string) error {
 
 		baseName := fmt.Sprintf("%s_%s_registry.mockpb.go", shortGeneratedFileIdentifier(f), path.Base(f.GeneratedFilenamePrefix))
 		if err = generateFileAndExecuteTemplate(plugin, "", []string{
-			"fmt",
+			"github.com/torqio/grpcmock/pkg/mocker",
 			"google.golang.org/grpc",
 		}, path.Join(cmdsDirectory, baseName), []string{RegistryTemplate}, f); err != nil {
 			return fmt.Errorf("create registry for %q: %w", baseName, err)
@@ -153,7 +170,7 @@ func generateCmds(plugin *protogen.Plugin, cmdsPath string) error {
 	return nil
 }
 
-func generateFile(plugin *protogen.Plugin, f *protogen.File) error {
+func generateFile(plugin *protogen.Plugin, f *protogen.File, crudFakes bool) error {
 	filename := f.GeneratedFilenamePrefix + "_grpcmock.pb.go"
 	return generateFileAndExecuteTemplate(plugin, f.GoImportPath, []string{
 		"context",
@@ -162,16 +179,33 @@ func generateFile(plugin *protogen.Plugin, f *protogen.File) error {
 		"errors",
 		"io",
 		"github.com/torqio/grpcmock/pkg/mocker",
+		"github.com/torqio/grpcmock/pkg/stub",
 		"google.golang.org/grpc",
 		"google.golang.org/grpc/codes",
 		"google.golang.org/grpc/status",
-	}, filename, []string{MockServerTemplate}, f)
+		"google.golang.org/protobuf/proto",
+	}, filename, []string{MockServerTemplate}, mockFileData{File: f, CRUDFakes: crudFakes})
+}
+
+// generateRemoteMockFile generates the remote mocks of the services of f in a file of their own, so only the users of
+// the remote mocks depend on the admin client
+func generateRemoteMockFile(plugin *protogen.Plugin, f *protogen.File) error {
+	filename := f.GeneratedFilenamePrefix + "_grpcmock_remote.pb.go"
+	return generateFileAndExecuteTemplate(plugin, f.GoImportPath, []string{
+		"context",
+		"testing",
+		"github.com/torqio/grpcmock/pkg/adminclient",
+		"google.golang.org/grpc",
+		"google.golang.org/protobuf/proto",
+	}, filename, []string{RemoteMockTemplate}, f)
 }
 
 func main() {
 	var flags flag.FlagSet
-	shouldGenerateCmds := flags.Bool("generate-cmds", false, "[Currently not in use] Generate cmds main packages for mocked services")
+	shouldGenerateCmds := flags.Bool("generate-cmds", false, "Generate cmds main packages for mocked services")
 	cmdsPath := flags.String("cmds-path", "", "Path to generate to cmds for the mocked services")
+	remoteMocks := flags.Bool("remote-mocks", false, "Generate remote mocks configuring the mock servers generated by generate-cmds")
+	crudFakes := flags.Bool("crud-fakes", false, "Generate in-memory CRUD fakes of the services with standard methods")
 	generated := false
 
 	protogen.Options{
@@ -185,9 +219,14 @@ func main() {
 				continue
 			}
 
-			if err := generateFile(plugin, f); err != nil {
+			if err := generateFile(plugin, f, *crudFakes); err != nil {
 				return fmt.Errorf("generate file %q: %w", f.GeneratedFilenamePrefix, err)
 			}
+			if *remoteMocks {
+				if err := generateRemoteMockFile(plugin, f); err != nil {
+					return fmt.Errorf("generate remote mock file %q: %w", f.GeneratedFilenamePrefix, err)
+				}
+			}
 			generated = true
 		}
 
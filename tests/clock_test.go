@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/mocker"
+)
+
+func TestClockFake(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "clock.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - request: {req: now}
+    response: {res: '{{ now }}'}
+    delay: 1h
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "clock-stream.stubs.yaml"), []byte(`
+method: ExampleStreamResponse
+cases:
+  - messages:
+      - message: {res: first}
+      - message: {res: '{{ now }}'}
+        delay: 10m
+`), 0o644))
+	testServer, client := startStubsMockServer(t, stubsDir)
+	clock := mocker.NewFakeClock(start)
+	testServer.mocker.SetClock(clock)
+	require.NoError(t, testServer.mocker.SetMethodFaultProfile(_ExampleService_ExampleMethodMethodName, &mocker.FaultProfile{
+		Latency: &mocker.Latency{Min: time.Minute},
+	}))
+
+	t.Run("latency, delay and template", func(t *testing.T) {
+		results := make(chan string, 1)
+		go func() {
+			res, err := client.ExampleMethod(context.Background(), &ExampleMethodRequest{Req: "now"})
+			assert.NoError(t, err)
+			results <- res.GetRes()
+		}()
+
+		// The injected latency, and then the stub delay
+		clock.BlockUntilTimers(1)
+		clock.Advance(time.Minute)
+		clock.BlockUntilTimers(1)
+		select {
+		case <-results:
+			t.Fatal("the call returned before its delay elapsed")
+		default:
+		}
+		clock.Advance(time.Hour)
+
+		// The response is rendered before the delay
+		assert.Equal(t, start.Add(time.Minute).Format(time.RFC3339Nano), <-results)
+		calls := testServer.mocker.Calls()
+		require.Len(t, calls, 1)
+		assert.Equal(t, start.Add(time.Minute), calls[0].Time)
+	})
+
+	t.Run("stream message delay", func(t *testing.T) {
+		stream, err := client.ExampleStreamResponse(context.Background(), &ExampleMethodRequest{})
+		require.NoError(t, err)
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "first", res.GetRes())
+
+		clock.BlockUntilTimers(1)
+		clock.Advance(10 * time.Minute)
+		res, err = stream.Recv()
+		require.NoError(t, err)
+		// Stream messages are rendered together, before they're sent
+		assert.Equal(t, start.Add(time.Minute+time.Hour).Format(time.RFC3339Nano), res.GetRes())
+	})
+}
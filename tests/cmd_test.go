@@ -0,0 +1,366 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/admin/adminv1"
+	"github.com/torqio/grpcmock/pkg/adminclient"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// writeStub writes a stub request and response files for the given method into dir
+func writeStub(t *testing.T, dir, name, method, request, response string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+"__"+method+"__request.json"), []byte(request), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+"__"+method+"__response.json"), []byte(response), 0o644))
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	return lis.Addr().String()
+}
+
+// startCmdServer builds the generated grpcmock_cmds server (generated by `make test` with the generate-cmds option),
+// starts it with the given stubs directory and returns a connection to it.
+func startCmdServer(t *testing.T, stubsDir string, args ...string) *grpc.ClientConn {
+	t.Helper()
+
+	binary := filepath.Join(t.TempDir(), "server")
+	build := exec.Command("go", "build", "-o", binary, "./grpcmock_cmds")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	addr := freeAddr(t)
+	cmd := exec.Command(binary, append([]string{"-addr", addr, "-http-addr", "", "-stubs-dir", stubsDir}, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	require.Eventually(t, func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "grpcmock.example.ExampleService"})
+		return err == nil && res.GetStatus() == healthpb.HealthCheckResponse_SERVING
+	}, 10*time.Second, 100*time.Millisecond)
+
+	return conn
+}
+
+func TestCmdServerStubs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "unary", "ExampleMethod", `{"req": "stubbed"}`, `{"res": "from-stub"}`)
+	writeStub(t, stubsDir, "stream", "ExampleStreamResponse", `{"req": "stubbed"}`, `{"res": "from-stub"}`)
+
+	client := NewExampleServiceClient(startCmdServer(t, stubsDir))
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "stubbed"})
+	require.NoError(t, err)
+	assert.Equal(t, "from-stub", res.GetRes())
+
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "not-stubbed"})
+	require.Error(t, err)
+
+	stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "stubbed"})
+	require.NoError(t, err)
+	streamRes, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "from-stub", streamRes.GetRes())
+}
+
+func TestCmdServerFakeUnmatched(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "unary", "ExampleMethod", `{"req": "stubbed"}`, `{"res": "from-stub"}`)
+
+	client := NewExampleServiceClient(startCmdServer(t, stubsDir, "-fake-unmatched", "-fake-seed", "1"))
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "stubbed"})
+	require.NoError(t, err)
+	assert.Equal(t, "from-stub", res.GetRes())
+	res, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "not-stubbed"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, res.GetRes())
+}
+
+func TestCmdServerValidate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "account", "CreateAccount", `{}`, `{"id": "1", "email": "a@example.com"}`)
+
+	client := NewAccountServiceClient(startCmdServer(t, stubsDir, "-validate"))
+
+	_, err := client.CreateAccount(ctx, &CreateAccountRequest{Email: "not an email", Age: 18})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	res, err := client.CreateAccount(ctx, &CreateAccountRequest{Email: "a@example.com", Age: 18})
+	require.NoError(t, err)
+	assert.Equal(t, "1", res.GetId())
+}
+
+func TestCmdServerStubsReload(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "unary", "ExampleMethod", `{}`, `{"res": "v1"}`)
+
+	client := NewExampleServiceClient(startCmdServer(t, stubsDir, "-stubs-poll-interval", "10ms"))
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "v1", res.GetRes())
+
+	writeStub(t, stubsDir, "unary", "ExampleMethod", `{}`, `{"res": "v2"}`)
+	require.Eventually(t, func() bool {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+		return err == nil && res.GetRes() == "v2"
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestCmdStubsLint(t *testing.T) {
+	t.Parallel()
+
+	binary := filepath.Join(t.TempDir(), "grpcmock")
+	build := exec.Command("go", "build", "-o", binary, "github.com/torqio/grpcmock/cmd/grpcmock")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	descriptorSet := filepath.Join(t.TempDir(), "set.binpb")
+	b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(File_svc_proto)}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(descriptorSet, b, 0o644))
+
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "valid", "ExampleMethod", `{"req": "valid"}`, `{"res": "valid"}`)
+	out, err = exec.Command(binary, "stubs", "lint", "--descriptor", descriptorSet, "--dir", stubsDir).CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "All 1 stub(s) are valid")
+
+	writeStub(t, stubsDir, "invalid", "ExampleMethod", `{"req": "invalid"}`, `{"unknown": "field"}`)
+	out, err = exec.Command(binary, "stubs", "lint", "--descriptor", descriptorSet, "--dir", stubsDir).CombinedOutput()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+	assert.Contains(t, string(out), "invalid response for grpcmock.example.ExampleMethodResponse")
+	assert.Contains(t, string(out), "Found 1 issue(s) in 2 stub(s)")
+}
+
+func newStruct(t *testing.T, fields map[string]any) *structpb.Struct {
+	t.Helper()
+	s, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return s
+}
+
+func TestCmdServerAdmin(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "unary", "ExampleMethod", `{"req": "stubbed"}`, `{"res": "from-stub"}`)
+	httpAddr := freeAddr(t)
+	conn := startCmdServer(t, stubsDir, "-http-addr", httpAddr)
+	client := NewExampleServiceClient(conn)
+	adminClient := adminv1.NewAdminServiceClient(conn)
+
+	const method = "/grpcmock.example.ExampleService/ExampleMethod"
+	methods, err := adminClient.ListMethods(ctx, &adminv1.ListMethodsRequest{})
+	require.NoError(t, err)
+	assert.Contains(t, methods.GetMethods(), method)
+
+	added, err := adminClient.AddExpectation(ctx, &adminv1.AddExpectationRequest{Expectation: &adminv1.Expectation{
+		Method:  method,
+		Request: newStruct(t, map[string]any{"req": "configured"}),
+		Return:  &adminv1.Return{Responses: []*structpb.Struct{newStruct(t, map[string]any{"res": "from-admin"})}},
+	}})
+	require.NoError(t, err)
+	require.NotEmpty(t, added.GetId())
+	_, err = adminClient.AddExpectation(ctx, &adminv1.AddExpectationRequest{Expectation: &adminv1.Expectation{
+		Method:  method,
+		Request: newStruct(t, map[string]any{"req": "failing"}),
+		Return:  &adminv1.Return{Status: &adminv1.Status{Code: "NOT_FOUND", Message: "not here"}},
+	}})
+	require.NoError(t, err)
+	_, err = adminClient.SetDefault(ctx, &adminv1.SetDefaultRequest{
+		Method: method,
+		Return: &adminv1.Return{Responses: []*structpb.Struct{newStruct(t, map[string]any{"res": "from-default"})}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		req         string
+		expectedRes string
+	}{
+		{req: "configured", expectedRes: "from-admin"},
+		{req: "stubbed", expectedRes: "from-stub"},
+		{req: "other", expectedRes: "from-default"},
+	}
+	for _, tc := range tests {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: tc.req})
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedRes, res.GetRes(), tc.req)
+	}
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "failing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	count, err := adminClient.GetCallCount(ctx, &adminv1.GetCallCountRequest{Method: method})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, count.GetCount())
+
+	calls, err := adminClient.ListCalls(ctx, &adminv1.ListCallsRequest{Method: method})
+	require.NoError(t, err)
+	require.Len(t, calls.GetCalls(), 4)
+	assert.Equal(t, "configured", calls.GetCalls()[0].GetRequest().GetFields()["req"].GetStringValue())
+	assert.True(t, calls.GetCalls()[0].GetMatched())
+
+	_, err = adminClient.DeleteExpectation(ctx, &adminv1.DeleteExpectationRequest{Method: method, Id: added.GetId()})
+	require.NoError(t, err)
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "configured"})
+	require.NoError(t, err)
+	assert.Equal(t, "from-default", res.GetRes())
+
+	_, err = adminClient.AddExpectation(ctx, &adminv1.AddExpectationRequest{Expectation: &adminv1.Expectation{Method: "/unknown.Service/Method"}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	t.Run("http", func(t *testing.T) {
+		post := func(adminMethod, body string) (int, string) {
+			httpRes, err := http.Post("http://"+httpAddr+"/v1/"+adminMethod, "application/json", strings.NewReader(body))
+			require.NoError(t, err)
+			defer httpRes.Body.Close()
+			resBody, err := io.ReadAll(httpRes.Body)
+			require.NoError(t, err)
+			return httpRes.StatusCode, string(resBody)
+		}
+
+		code, body := post("Reset", `{}`)
+		require.Equal(t, http.StatusOK, code, body)
+		code, body = post("AddExpectation", `{"expectation": {"method": "`+method+`", "return": {"responses": [{"res": "from-http"}]}}}`)
+		require.Equal(t, http.StatusOK, code, body)
+
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "anything"})
+		require.NoError(t, err)
+		assert.Equal(t, "from-http", res.GetRes())
+
+		code, body = post("GetCallCount", `{"method": "`+method+`"}`)
+		require.Equal(t, http.StatusOK, code, body)
+		assert.JSONEq(t, `{"count": 1}`, body)
+
+		code, body = post("SetDefault", `{"method": "/unknown.Service/Method\u0001"}`)
+		assert.Equal(t, http.StatusNotFound, code)
+		var httpErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(body), &httpErr), body)
+		assert.Equal(t, codes.NotFound.String(), httpErr.Code)
+		assert.Contains(t, httpErr.Message, "unknown method")
+	})
+}
+
+func TestCmdServerRemoteMock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	conn := startCmdServer(t, t.TempDir())
+	client := NewExampleServiceClient(conn)
+	remoteMock := NewExampleServiceRemoteMockT(t, conn)
+
+	call := remoteMock.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "remote"}).Return(&ExampleMethodResponse{Res: "from-remote"}, nil)
+	remoteMock.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "failing"}).Return(nil, status.Error(codes.PermissionDenied, "denied"))
+	remoteMock.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{Res: "from-default"}, nil)
+	remoteMock.Configure().ExampleStreamResponse().On(&ExampleMethodRequest{Req: "remote"}, mocker.Any()).Return([]*ExampleMethodResponse{{Res: "first"}, {Res: "second"}}, nil)
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "remote"})
+	require.NoError(t, err)
+	assert.Equal(t, "from-remote", res.GetRes())
+
+	res, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "other"})
+	require.NoError(t, err)
+	assert.Equal(t, "from-default", res.GetRes())
+
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "failing"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "denied", status.Convert(err).Message())
+	assert.Equal(t, 3, remoteMock.Configure().ExampleMethod().TimesCalled())
+
+	stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "remote"})
+	require.NoError(t, err)
+	for _, expectedRes := range []string{"first", "second"} {
+		streamRes, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, expectedRes, streamRes.GetRes())
+	}
+
+	call.Delete()
+	remoteMock.Configure().ExampleMethod().DeleteDefault()
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "remote"})
+	require.Error(t, err)
+
+	remoteMock.ResetAll()
+	assert.Equal(t, 0, remoteMock.Configure().ExampleMethod().TimesCalled())
+
+	// Expectations which failed to be added (and were reported) can still be deleted
+	unreportedMock := NewExampleServiceRemoteMock(adminclient.New(conn))
+	failed := unreportedMock.Configure().ExampleMethod().On(mocker.Any(), "not a message").Return(&ExampleMethodResponse{}, nil)
+	assert.Empty(t, failed.ID())
+	assert.NotPanics(t, failed.Delete)
+}
+
+func TestCmdServerFaults(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "unary", "ExampleMethod", `{}`, `{"res": "from-stub"}`)
+	faultsPath := filepath.Join(t.TempDir(), "faults.yaml")
+	require.NoError(t, os.WriteFile(faultsPath, []byte(`
+methods:
+  /grpcmock.example.ExampleService/ExampleMethod:
+    reset_probability: 1
+`), 0o644))
+
+	client := NewExampleServiceClient(startCmdServer(t, stubsDir, "-faults", faultsPath))
+	_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, mocker.ConnectionResetMessage, status.Convert(err).Message())
+}
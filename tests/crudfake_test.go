@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/crudfake"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func startLibraryCRUDFake(t *testing.T) (*LibraryServiceMockServer, *LibraryServiceCRUDFake, LibraryServiceClient) {
+	t.Helper()
+
+	testServer, client := StartLibraryServiceMockServer(t)
+	fake := NewLibraryServiceCRUDFake()
+	testServer.SetFallback(fake)
+	return testServer, fake, client
+}
+
+func TestCRUDFake(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, fake, client := startLibraryCRUDFake(t)
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	clock := mocker.NewFakeClock(start)
+	testServer.mocker.SetClock(clock)
+	assert.Equal(t, []string{
+		_LibraryService_CreateBookMethodName, _LibraryService_DeleteBookMethodName, _LibraryService_GetBookMethodName,
+		_LibraryService_ListBooksMethodName, _LibraryService_UpdateBookMethodName,
+	}, fake.Methods())
+
+	created, err := client.CreateBook(ctx, &CreateBookRequest{Parent: "publishers/1", BookId: "dune", Book: &Book{Title: "Dune", Author: "Herbert"}})
+	require.NoError(t, err)
+	assert.Equal(t, "publishers/1/books/dune", created.GetName())
+	assert.Equal(t, start, created.GetCreateTime().AsTime())
+	_, err = client.CreateBook(ctx, &CreateBookRequest{Parent: "publishers/1", BookId: "dune", Book: &Book{}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	generated, err := client.CreateBook(ctx, &CreateBookRequest{Book: &Book{Title: "Emma"}})
+	require.NoError(t, err)
+	assert.Regexp(t, "^books/[0-9a-f-]{36}$", generated.GetName())
+
+	got, err := client.GetBook(ctx, &GetBookRequest{Name: "publishers/1/books/dune"})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(created, got))
+	_, err = client.GetBook(ctx, &GetBookRequest{Name: "publishers/1/books/missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	t.Run("update with mask", func(t *testing.T) {
+		clock.Advance(time.Hour)
+		updated, err := client.UpdateBook(ctx, &UpdateBookRequest{
+			Book:       &Book{Name: "publishers/1/books/dune", Title: "Dune Messiah", Author: "ignored", Details: &BookDetails{Pages: 256, Language: "ignored"}},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title", "details.pages"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Dune Messiah", updated.GetTitle())
+		assert.Equal(t, "Herbert", updated.GetAuthor())
+		assert.Equal(t, int32(256), updated.GetDetails().GetPages())
+		assert.Empty(t, updated.GetDetails().GetLanguage())
+		assert.Equal(t, start, updated.GetCreateTime().AsTime())
+		assert.Equal(t, start.Add(time.Hour), updated.GetUpdateTime().AsTime())
+
+		_, err = client.UpdateBook(ctx, &UpdateBookRequest{
+			Book:       &Book{Name: "publishers/1/books/dune"},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"nope"}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("update without mask", func(t *testing.T) {
+		updated, err := client.UpdateBook(ctx, &UpdateBookRequest{Book: &Book{Name: "publishers/1/books/dune", Author: "Frank Herbert"}})
+		require.NoError(t, err)
+		assert.Equal(t, "Dune Messiah", updated.GetTitle())
+		assert.Equal(t, "Frank Herbert", updated.GetAuthor())
+
+		_, err = client.UpdateBook(ctx, &UpdateBookRequest{Book: &Book{Name: "publishers/1/books/missing"}})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		missing, err := client.UpdateBook(ctx, &UpdateBookRequest{Book: &Book{Name: "publishers/1/books/missing", Title: "Upserted"}, AllowMissing: true})
+		require.NoError(t, err)
+		assert.Equal(t, "Upserted", missing.GetTitle())
+	})
+
+	t.Run("list pages", func(t *testing.T) {
+		require.NoError(t, fake.Put(&Book{Name: "publishers/1/books/a", Title: "A"}, &Book{Name: "publishers/2/books/b", Title: "B"}))
+
+		page, err := client.ListBooks(ctx, &ListBooksRequest{Parent: "publishers/1", PageSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), page.GetTotalSize())
+		require.Len(t, page.GetBooks(), 2)
+		assert.Equal(t, "publishers/1/books/a", page.GetBooks()[0].GetName())
+		assert.Equal(t, "publishers/1/books/dune", page.GetBooks()[1].GetName())
+		require.NotEmpty(t, page.GetNextPageToken())
+
+		page, err = client.ListBooks(ctx, &ListBooksRequest{Parent: "publishers/1", PageSize: 2, PageToken: page.GetNextPageToken()})
+		require.NoError(t, err)
+		require.Len(t, page.GetBooks(), 1)
+		assert.Equal(t, "publishers/1/books/missing", page.GetBooks()[0].GetName())
+		assert.Empty(t, page.GetNextPageToken())
+
+		_, err = client.ListBooks(ctx, &ListBooksRequest{PageToken: "not base64!"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := client.DeleteBook(ctx, &DeleteBookRequest{Name: "publishers/1/books/dune"})
+		require.NoError(t, err)
+		_, err = client.GetBook(ctx, &GetBookRequest{Name: "publishers/1/books/dune"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+		_, err = client.DeleteBook(ctx, &DeleteBookRequest{Name: "publishers/1/books/dune"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	assert.Len(t, fake.Resources(), 4)
+	fake.Reset()
+	assert.Empty(t, fake.Resources())
+	assert.ErrorContains(t, fake.Put(&BookDetails{}), "isn't a resource")
+}
+
+func TestCRUDFakeOverride(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, fake, client := startLibraryCRUDFake(t)
+	require.NoError(t, fake.Put(&Book{Name: "books/stored", Title: "Stored"}))
+	testServer.Configure().GetBook().On(mocker.Any(), &GetBookRequest{Name: "books/mocked"}).Return(&Book{Name: "books/mocked", Title: "Mocked"}, nil)
+
+	mocked, err := client.GetBook(ctx, &GetBookRequest{Name: "books/mocked"})
+	require.NoError(t, err)
+	assert.Equal(t, "Mocked", mocked.GetTitle())
+	stored, err := client.GetBook(ctx, &GetBookRequest{Name: "books/stored"})
+	require.NoError(t, err)
+	assert.Equal(t, "Stored", stored.GetTitle())
+	assert.Equal(t, 2, testServer.Configure().GetBook().TimesCalled())
+
+	stream, err := client.WatchBooks(ctx, &ListBooksRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestCRUDFakeResourcesOfOtherPackages(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stringField := func(name string, number int32) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()}
+	}
+	// Both packages have a Book resource, served by the standard methods of the same service
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("shelf.proto"),
+		Package: proto.String("shelf"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Book"), Field: []*descriptorpb.FieldDescriptorProto{stringField("name", 1)}},
+			{Name: proto.String("GetBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{stringField("name", 1)}},
+		},
+	}, {
+		Name:       proto.String("store.proto"),
+		Package:    proto.String("store"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"shelf.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Book"), Field: []*descriptorpb.FieldDescriptorProto{stringField("name", 1)}},
+			{Name: proto.String("CreateBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{{
+				Name: proto.String("book"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), TypeName: proto.String(".store.Book"),
+			}}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("BookService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("GetBook"), InputType: proto.String(".shelf.GetBookRequest"), OutputType: proto.String(".shelf.Book")},
+				{Name: proto.String("CreateBook"), InputType: proto.String(".store.CreateBookRequest"), OutputType: proto.String(".store.Book")},
+			},
+		}},
+	}}})
+	require.NoError(t, err)
+	desc, err := files.FindDescriptorByName("store.BookService")
+	require.NoError(t, err)
+	sd := desc.(protoreflect.ServiceDescriptor)
+	require.True(t, crudfake.HasStandardMethods(sd))
+	fake := crudfake.New(sd)
+	method := func(name protoreflect.Name) protoreflect.MethodDescriptor {
+		return sd.Methods().ByName(name)
+	}
+
+	createReq := dynamicpb.NewMessage(method("CreateBook").Input())
+	createReq.Set(method("CreateBook").Input().Fields().ByName("book"), protoreflect.ValueOfMessage(dynamicpb.NewMessage(method("CreateBook").Output())))
+	created := dynamicpb.NewMessage(method("CreateBook").Output())
+	require.NoError(t, fake.Handle(ctx, mocker.MethodName(method("CreateBook")), createReq, created))
+	name := created.Get(method("CreateBook").Output().Fields().ByName("name")).String()
+	assert.Regexp(t, "^books/", name)
+
+	// The books of both packages are resources
+	shelfBook := dynamicpb.NewMessage(method("GetBook").Output())
+	shelfBook.Set(method("GetBook").Output().Fields().ByName("name"), protoreflect.ValueOfString("books/shelved"))
+	storeBook := dynamicpb.NewMessage(method("CreateBook").Output())
+	storeBook.Set(method("CreateBook").Output().Fields().ByName("name"), protoreflect.ValueOfString("books/stored"))
+	require.NoError(t, fake.Put(shelfBook, storeBook))
+	assert.Len(t, fake.Resources(), 3)
+
+	// The created book is a store.Book, not a shelf.Book
+	getReq := dynamicpb.NewMessage(method("GetBook").Input())
+	getReq.Set(method("GetBook").Input().Fields().ByName("name"), protoreflect.ValueOfString(name))
+	err = fake.Handle(ctx, mocker.MethodName(method("GetBook")), getReq, dynamicpb.NewMessage(method("GetBook").Output()))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/dynamicmock"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"github.com/torqio/grpcmock/pkg/stub"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func newDynamicMessage(t *testing.T, desc protoreflect.MessageDescriptor, field, value string) *dynamicpb.Message {
+	msg := dynamicpb.NewMessage(desc)
+	fd := desc.Fields().ByName(protoreflect.Name(field))
+	require.NotNil(t, fd)
+	msg.Set(fd, protoreflect.ValueOfString(value))
+	return msg
+}
+
+func TestDynamicMock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "ExampleMethod__request.json"), []byte(`{"req": "stubbed"}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "ExampleMethod__response.json"), []byte(`{"res": "from-stub"}`), 0o644))
+	stubs, err := stub.MapStubFiles(stubsDir)
+	require.NoError(t, err)
+
+	// Building the server from a descriptor set, like the output of `buf build -o`
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(File_svc_proto)}}
+	srv, err := dynamicmock.NewFromDescriptorSet(set, dynamicmock.WithStubs(stubs))
+	require.NoError(t, err)
+	require.Len(t, srv.Services(), 1)
+
+	harness := mocker.NewHarness(t)
+	harness.Register(srv)
+	client := NewExampleServiceClient(harness.Conn())
+
+	md := srv.Services()[0].Methods().ByName("ExampleMethod")
+	streamMD := srv.Services()[0].Methods().ByName("ExampleStreamResponse")
+	method := mocker.MethodName(md)
+
+	srv.Mocker().AddExpectedCallV2(method, []any{mocker.Any(), newDynamicMessage(t, md.Input(), "req", "expected")},
+		[]any{newDynamicMessage(t, md.Output(), "res", "from-expected-call"), nil})
+	srv.Mocker().SetDefaultCall(method, []any{newDynamicMessage(t, md.Output(), "res", "from-default"), nil})
+	srv.Mocker().SetDefaultCall(mocker.MethodName(streamMD), []any{[]proto.Message{
+		newDynamicMessage(t, streamMD.Output(), "res", "stream-1"),
+		newDynamicMessage(t, streamMD.Output(), "res", "stream-2"),
+	}, nil})
+
+	tests := []struct {
+		req         string
+		expectedRes string
+	}{
+		{req: "expected", expectedRes: "from-expected-call"},
+		{req: "stubbed", expectedRes: "from-stub"},
+		{req: "other", expectedRes: "from-default"},
+	}
+	for _, tc := range tests {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: tc.req})
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedRes, res.GetRes())
+	}
+	assert.Equal(t, len(tests), srv.Mocker().GetCallCount(method))
+
+	stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "stream"})
+	require.NoError(t, err)
+	for _, expectedRes := range []string{"stream-1", "stream-2"} {
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, expectedRes, res.GetRes())
+	}
+	_, err = stream.Recv()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestDynamicMockStreamRequestUnmatchedFirstMessage(t *testing.T) {
+	t.Parallel()
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(File_svc_proto)}}
+	srv, err := dynamicmock.NewFromDescriptorSet(set)
+	require.NoError(t, err)
+
+	harness := mocker.NewHarness(t)
+	harness.Register(srv)
+	client := NewExampleServiceClient(harness.Conn())
+
+	md := srv.Services()[0].Methods().ByName("ExampleStreamRequest")
+	srv.Mocker().AddExpectedCallV2(mocker.MethodName(md), []any{newDynamicMessage(t, md.Input(), "req", "matched"), mocker.Any()},
+		[]any{newDynamicMessage(t, md.Output(), "res", "from-expected-call"), nil})
+
+	// The first message matches nothing, the stream is answered by the second one
+	stream, err := client.ExampleStreamRequest(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "unmatched"}))
+	require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "matched"}))
+	res, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, "from-expected-call", res.GetRes())
+}
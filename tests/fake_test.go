@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFake(t *testing.T) {
+	t.Parallel()
+
+	catalog := mocker.Fake[*Catalog](mocker.WithFakeSeed(1))
+	assert.True(t, proto.Equal(catalog, mocker.Fake[*Catalog](mocker.WithFakeSeed(1))))
+	assert.False(t, proto.Equal(catalog, mocker.Fake[*Catalog](mocker.WithFakeSeed(2))))
+
+	assert.Contains(t, []Catalog_Status{Catalog_STATUS_OPEN, Catalog_STATUS_CLOSED}, catalog.GetStatus())
+	assert.NotNil(t, catalog.GetOwner())
+	assert.NotEmpty(t, catalog.GetLabels())
+	assert.NotEmpty(t, catalog.GetChecksum())
+	assert.NotNil(t, catalog.Rating)
+	assert.Nil(t, catalog.GetExtra())
+	require.NoError(t, catalog.GetLoanPeriod().CheckValid())
+	assert.Positive(t, catalog.GetLoanPeriod().AsDuration())
+	assert.NotEmpty(t, catalog.GetMetadata().GetFields())
+	require.NotEmpty(t, catalog.GetBooks())
+	for _, book := range catalog.GetBooks() {
+		assert.NotEmpty(t, book.GetName())
+		require.NoError(t, book.GetCreateTime().CheckValid())
+	}
+	assert.GreaterOrEqual(t, len(catalog.GetTags()), 1)
+	assert.LessOrEqual(t, len(catalog.GetTags()), mocker.DefaultFakeListLen)
+
+	// The nested messages end at the depth
+	require.NotNil(t, catalog.GetParent().GetParent())
+	assert.Nil(t, catalog.GetParent().GetParent().GetParent())
+	shallow := mocker.Fake[*Catalog](mocker.WithFakeDepth(1))
+	assert.Nil(t, shallow.GetParent())
+	assert.Empty(t, shallow.GetBooks())
+	assert.NotEmpty(t, shallow.GetTags())
+
+	// Valid messages are marshalled with no error, unlike invalid timestamps, non-finite numbers or invalid UTF-8
+	_, err := protojson.Marshal(catalog)
+	require.NoError(t, err)
+}
+
+func TestFakeDefaultReturn(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := StartLibraryServiceMockServer(t)
+	testServer.Configure().GetBook().DefaultReturnFake(mocker.WithFakeSeed(1))
+	testServer.Configure().WatchBooks().DefaultReturnFake(mocker.WithFakeListLen(1))
+	testServer.Configure().GetBook().On(mocker.Any(), &GetBookRequest{Name: "books/mocked"}).Return(&Book{Name: "books/mocked"}, nil)
+
+	first, err := client.GetBook(ctx, &GetBookRequest{Name: "books/1"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.GetName())
+	assert.NotEmpty(t, first.GetDetails().GetLanguage())
+	second, err := client.GetBook(ctx, &GetBookRequest{Name: "books/2"})
+	require.NoError(t, err)
+	assert.False(t, proto.Equal(first, second))
+	mocked, err := client.GetBook(ctx, &GetBookRequest{Name: "books/mocked"})
+	require.NoError(t, err)
+	assert.Equal(t, "books/mocked", mocked.GetName())
+
+	stream, err := client.WatchBooks(ctx, &ListBooksRequest{})
+	require.NoError(t, err)
+	streamed, err := stream.Recv()
+	require.NoError(t, err)
+	assert.NotEmpty(t, streamed.GetTitle())
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestFakeUnmatched(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := StartExampleServiceMockServer(t)
+	testServer.mocker.SetFakeUnmatched(mocker.NewFaker(mocker.WithFakeSeed(1)))
+	testServer.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "mocked"}).Return(&ExampleMethodResponse{Res: "mocked"}, nil)
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "mocked"})
+	require.NoError(t, err)
+	assert.Equal(t, "mocked", res.GetRes())
+	res, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "unmatched"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, res.GetRes())
+
+	requestStream, err := client.ExampleStreamRequest(ctx)
+	require.NoError(t, err)
+	require.NoError(t, requestStream.Send(&ExampleMethodRequest{Req: "unmatched"}))
+	res, err = requestStream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.NotEmpty(t, res.GetRes())
+
+	responseStream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "unmatched"})
+	require.NoError(t, err)
+	var streamed []*ExampleMethodResponse
+	for {
+		res, err := responseStream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		streamed = append(streamed, res)
+	}
+	assert.NotEmpty(t, streamed)
+
+	testServer.mocker.SetFakeUnmatched(nil)
+	testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{Res: "default"}, nil)
+	res, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "unmatched"})
+	require.NoError(t, err)
+	assert.Equal(t, "default", res.GetRes())
+}
@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestFaultsStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := StartExampleServiceMockServer(t)
+	testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{Res: "ok"}, nil)
+	testServer.Configure().ExampleStreamResponse().DefaultReturn([]*ExampleMethodResponse{{Res: "ok"}}, nil)
+
+	m := testServer.mocker
+	require.NoError(t, m.SetFaultProfile(&mocker.FaultProfile{Errors: []mocker.StatusFault{{Code: codes.Unavailable, Probability: 1}}}))
+	require.NoError(t, m.SetMethodFaultProfile(_ExampleService_ExampleMethodMethodName, &mocker.FaultProfile{
+		Errors: []mocker.StatusFault{{Code: codes.PermissionDenied, Message: "denied", Probability: 1}},
+	}))
+
+	_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Equal(t, "denied", status.Convert(err).Message())
+	// Faulted calls don't reach the mock
+	assert.Equal(t, 0, testServer.Configure().ExampleMethod().TimesCalled())
+
+	stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	require.NoError(t, m.SetMethodFaultProfile(_ExampleService_ExampleMethodMethodName, nil))
+	require.NoError(t, m.SetFaultProfile(nil))
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "ok", res.GetRes())
+
+	assert.ErrorContains(t, m.SetFaultProfile(&mocker.FaultProfile{
+		Errors:           []mocker.StatusFault{{Code: codes.Unavailable, Probability: 0.8}},
+		ResetProbability: 0.5,
+	}), "must add up to between 0 and 1")
+}
+
+func TestFaultsSeeded(t *testing.T) {
+	t.Parallel()
+
+	callCodes := func() []codes.Code {
+		testServer, client := StartExampleServiceMockServer(t)
+		testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{}, nil)
+		require.NoError(t, testServer.mocker.SetFaultProfile(&mocker.FaultProfile{
+			Errors:           []mocker.StatusFault{{Code: codes.Unavailable, Probability: 0.3}},
+			ResetProbability: 0.2,
+			Seed:             42,
+		}))
+
+		var got []codes.Code
+		for i := 0; i < 30; i++ {
+			_, err := client.ExampleMethod(context.Background(), &ExampleMethodRequest{})
+			got = append(got, status.Code(err))
+		}
+		return got
+	}
+
+	first := callCodes()
+	assert.Equal(t, first, callCodes())
+	assert.Contains(t, first, codes.OK)
+	assert.Contains(t, first, codes.Unavailable)
+}
+
+func TestFaultsLatency(t *testing.T) {
+	t.Parallel()
+
+	testServer, client := StartExampleServiceMockServer(t)
+	testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{}, nil)
+	require.NoError(t, testServer.mocker.SetFaultProfile(&mocker.FaultProfile{Latency: &mocker.Latency{Min: 50 * time.Millisecond, Max: 60 * time.Millisecond}}))
+
+	start := time.Now()
+	_, err := client.ExampleMethod(context.Background(), &ExampleMethodRequest{})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// The latency is cut short by the deadline of the call
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestFaultsStreams(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := StartExampleServiceMockServer(t)
+	testServer.Configure().ExampleStreamResponse().DefaultReturn([]*ExampleMethodResponse{{Res: "1"}, {Res: "2"}, {Res: "3"}}, nil)
+	method := _ExampleService_ExampleStreamResponseMethodName
+
+	t.Run("truncation", func(t *testing.T) {
+		require.NoError(t, testServer.mocker.SetMethodFaultProfile(method, &mocker.FaultProfile{TruncateProbability: 1}))
+		stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.True(t, errors.Is(err, io.EOF))
+	})
+
+	t.Run("connection reset", func(t *testing.T) {
+		require.NoError(t, testServer.mocker.SetMethodFaultProfile(method, &mocker.FaultProfile{ResetProbability: 1}))
+		stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, mocker.ConnectionResetMessage, status.Convert(err).Message())
+	})
+
+	t.Run("mid-stream", func(t *testing.T) {
+		// With the default seed, the stream is truncated after its first message
+		require.NoError(t, testServer.mocker.SetMethodFaultProfile(method, &mocker.FaultProfile{TruncateProbability: 0.5}))
+		stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{})
+		require.NoError(t, err)
+		var received []string
+		for {
+			res, err := stream.Recv()
+			if err != nil {
+				assert.True(t, errors.Is(err, io.EOF))
+				break
+			}
+			received = append(received, res.GetRes())
+		}
+		assert.Equal(t, []string{"1"}, received)
+	})
+}
+
+func TestFaultsConfig(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "faults.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+global:
+  latency: {min: 10ms, max: 20ms}
+methods:
+  /grpcmock.example.ExampleService/ExampleMethod:
+    errors:
+      - {code: RESOURCE_EXHAUSTED, message: slow down, probability: 1}
+    seed: 7
+  /grpcmock.example.ExampleService/ExampleStreamResponse:
+    rate_limit: {requests: 10, per: 1m, metadata_key: x-api-key}
+`), 0o644))
+	config, err := mocker.LoadFaultConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, &mocker.Latency{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}, config.Global.Latency)
+	assert.Equal(t, &mocker.RateLimit{Requests: 10, Per: time.Minute, MetadataKey: "x-api-key"}, config.Methods[_ExampleService_ExampleStreamResponseMethodName].RateLimit)
+
+	testServer, client := StartExampleServiceMockServer(t)
+	require.NoError(t, config.Apply(testServer.mocker))
+	_, err = client.ExampleMethod(context.Background(), &ExampleMethodRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, "slow down", status.Convert(err).Message())
+
+	require.NoError(t, os.WriteFile(path, []byte("global:\n  errors: [{code: NOPE, probability: 1}]\n"), 0o644))
+	_, err = mocker.LoadFaultConfig(path)
+	assert.ErrorContains(t, err, `invalid status code "NOPE"`)
+}
+
+func TestFaultsRateLimit(t *testing.T) {
+	t.Parallel()
+
+	testServer, client := StartExampleServiceMockServer(t)
+	testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{}, nil)
+	clock := mocker.NewFakeClock(time.Unix(0, 0))
+	testServer.mocker.SetClock(clock)
+	require.NoError(t, testServer.mocker.SetMethodFaultProfile(_ExampleService_ExampleMethodMethodName, &mocker.FaultProfile{
+		RateLimit: &mocker.RateLimit{Requests: 2, Per: time.Second, Burst: 3, MetadataKey: "x-api-key"},
+	}))
+
+	call := func(apiKey string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", apiKey)
+		_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+		return err
+	}
+	retryDelay := func(err error) time.Duration {
+		for _, detail := range status.Convert(err).Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				return info.GetRetryDelay().AsDuration()
+			}
+		}
+		t.Fatalf("no RetryInfo in %v", err)
+		return 0
+	}
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, call("a"))
+	}
+	err := call("a")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 500*time.Millisecond, retryDelay(err))
+	// Each API key has its own bucket
+	require.NoError(t, call("b"))
+
+	clock.Advance(300 * time.Millisecond)
+	err = call("a")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, 200*time.Millisecond, retryDelay(err))
+
+	clock.Advance(200 * time.Millisecond)
+	require.NoError(t, call("a"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("a")))
+	// Rejected calls don't reach the mock
+	assert.Equal(t, 5, testServer.Configure().ExampleMethod().TimesCalled())
+
+	assert.ErrorContains(t, testServer.mocker.SetFaultProfile(&mocker.FaultProfile{RateLimit: &mocker.RateLimit{Requests: 1}}), "period must be positive")
+}
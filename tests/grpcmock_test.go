@@ -340,6 +340,37 @@ func TestGRPCMockStreamRequest(t *testing.T) {
 	})
 }
 
+// TestGRPCMockStreamRequestUnmatchedFirstMessage tests that an unmatched message doesn't fail the stream when a later
+// message of it matches an expected call.
+func TestGRPCMockStreamRequestUnmatchedFirstMessage(t *testing.T) {
+	t.Parallel()
+
+	testServer, err := NewExampleServiceMockServer()
+	require.NoError(t, err)
+	addr := startGrpcServer(t, testServer)
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	client := NewExampleServiceClient(conn)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	call := testServer.Configure().ExampleStreamRequest().
+		On(&ExampleMethodRequest{Req: "matched"}, mocker.Any()).
+		Return(&ExampleMethodResponse{Res: "from-expected-call"}, nil)
+
+	stream, err := client.ExampleStreamRequest(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "unmatched"}))
+	require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "matched"}))
+	res, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, "from-expected-call", res.GetRes())
+	assert.Equal(t, 1, call.TimesCalled())
+}
+
 // Testing the case where the server is sending a stream of responses and the client is sending a stream of requests.
 // The test is checking that the server is returning the expected responses stream for each request is receives in the stream.
 // If there is no expected request matched for a given request in the stream, the server will return the default responses stream.
@@ -455,8 +486,11 @@ func TestGRPCMockStreamRequestResponse(t *testing.T) {
 		require.NoError(t, err)
 		err = stream.Send(&ExampleMethodRequest{Req: uuid.NewString()})
 		require.NoError(t, err)
+		// Unmatched messages are skipped, so the stream fails only once closed
+		require.NoError(t, stream.CloseSend())
 		_, err = stream.Recv()
 		require.Error(t, err)
+		assert.Equal(t, codes.NotFound, status.Code(err))
 		assert.Contains(t, err.Error(), "no matching expected call nor default return for method ExampleStreamRequestResponse with given arguments")
 	})
 }
@@ -624,3 +658,77 @@ func TestDoAndReturnDefaultStreamResponse(t *testing.T) {
 		assert.True(t, errors.Is(err, io.EOF))
 	}
 }
+
+// realExampleService is a real implementation of ExampleService, echoing the requests
+type realExampleService struct {
+	UnimplementedExampleServiceServer
+}
+
+func (realExampleService) ExampleMethod(_ context.Context, req *ExampleMethodRequest) (*ExampleMethodResponse, error) {
+	return &ExampleMethodResponse{Res: "real-" + req.GetReq()}, nil
+}
+
+func (realExampleService) ExampleStreamResponse(req *ExampleMethodRequest, stream ExampleService_ExampleStreamResponseServer) error {
+	return stream.Send(&ExampleMethodResponse{Res: "real-" + req.GetReq()})
+}
+
+func (realExampleService) ExampleStreamRequest(stream ExampleService_ExampleStreamRequestServer) error {
+	res := "real"
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&ExampleMethodResponse{Res: res})
+		}
+		if err != nil {
+			return err
+		}
+		res += "-" + req.GetReq()
+	}
+}
+
+func TestGRPCMockFallback(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer := NewExampleServiceMockServerWithFallback(realExampleService{})
+	testServer.mocker.SetT(t)
+	harness := mocker.NewHarness(t)
+	harness.Register(testServer)
+	client := NewExampleServiceClient(harness.Conn())
+
+	testServer.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "mocked"}).Return(&ExampleMethodResponse{Res: "mocked"}, nil)
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "mocked"})
+	require.NoError(t, err)
+	assert.Equal(t, "mocked", res.GetRes())
+	res, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "other"})
+	require.NoError(t, err)
+	assert.Equal(t, "real-other", res.GetRes())
+	assert.Equal(t, 2, testServer.Configure().ExampleMethod().TimesCalled())
+
+	stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "stream"})
+	require.NoError(t, err)
+	streamRes, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "real-stream", streamRes.GetRes())
+	_, err = stream.Recv()
+	assert.True(t, errors.Is(err, io.EOF))
+
+	// The messages received before the stream was delegated are received by the fallback too
+	clientStream, err := client.ExampleStreamRequest(ctx)
+	require.NoError(t, err)
+	for _, req := range []string{"a", "b", "c"} {
+		require.NoError(t, clientStream.Send(&ExampleMethodRequest{Req: req}))
+	}
+	res, err = clientStream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, "real-a-b-c", res.GetRes())
+	assert.Equal(t, 3, testServer.Configure().ExampleStreamRequest().TimesCalled())
+
+	// Methods the fallback doesn't implement fail like it does
+	bidiStream, err := client.ExampleStreamRequestResponse(ctx)
+	require.NoError(t, err)
+	require.NoError(t, bidiStream.Send(&ExampleMethodRequest{Req: "bidi"}))
+	require.NoError(t, bidiStream.CloseSend())
+	_, err = bidiStream.Recv()
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
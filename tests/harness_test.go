@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestStartMockServer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := StartExampleServiceMockServer(t)
+
+	testServer.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "req"}).Return(&ExampleMethodResponse{Res: "res"}, nil)
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "req"})
+	require.NoError(t, err)
+	assert.Equal(t, "res", res.GetRes())
+	assert.Equal(t, 1, testServer.Configure().ExampleMethod().TimesCalled())
+}
+
+func TestStartMockServerWithOptions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	var intercepted int32
+	testServer, client := StartExampleServiceMockServer(t,
+		mocker.WithTCPListener("127.0.0.1:0"),
+		mocker.WithUnaryInterceptors(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			atomic.AddInt32(&intercepted, 1)
+			return handler(ctx, req)
+		}),
+	)
+
+	testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{Res: "default"}, nil)
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "req"})
+	require.NoError(t, err)
+	assert.Equal(t, "default", res.GetRes())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&intercepted))
+}
+
+func TestHarnessMultipleServices(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	exampleServer := NewExampleServiceMockServerT(t)
+	unaryServer := NewUnaryOnlySvcMockServerT(t)
+
+	harness := mocker.NewHarness(t, mocker.WithTCPListener("127.0.0.1:0"))
+	harness.Register(exampleServer, unaryServer)
+
+	exampleServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{Res: "example"}, nil)
+	unaryServer.Configure().ExampleMethod().DefaultReturn(&UnaryRes{Res: "unary"}, nil)
+
+	exampleRes, err := NewExampleServiceClient(harness.Conn()).ExampleMethod(ctx, &ExampleMethodRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "example", exampleRes.GetRes())
+
+	unaryRes, err := NewUnaryOnlySvcClient(harness.Conn()).ExampleMethod(ctx, &UnaryReq{})
+	require.NoError(t, err)
+	assert.Equal(t, "unary", unaryRes.GetRes())
+
+	// The harness address is reachable from outside when served on a TCP listener
+	conn, err := grpc.NewClient(harness.Addr(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	unaryRes, err = NewUnaryOnlySvcClient(conn).ExampleMethod(ctx, &UnaryReq{})
+	require.NoError(t, err)
+	assert.Equal(t, "unary", unaryRes.GetRes())
+	assert.Equal(t, 2, unaryServer.Configure().ExampleMethod().TimesCalled())
+}
+
+// selfSignedCert creates a self-signed certificate of the given host
+func selfSignedCert(t *testing.T, host string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestHarnessWithTLS(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cert, pool := selfSignedCert(t, "grpcmock.test")
+	serverCreds := credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})
+	clientCreds := credentials.NewTLS(&tls.Config{RootCAs: pool, ServerName: "grpcmock.test"})
+
+	t.Run("trusted certificate", func(t *testing.T) {
+		t.Parallel()
+
+		testServer := NewExampleServiceMockServerT(t)
+		harness := mocker.NewHarness(t, mocker.WithTLS(serverCreds, clientCreds))
+		harness.Register(testServer)
+		testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{Res: "secure"}, nil)
+
+		// The harness is started by the first of concurrent calls of Conn, off the test goroutine
+		var wg sync.WaitGroup
+		results := make([]string, 3)
+		for i := range results {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := NewExampleServiceClient(harness.Conn()).ExampleMethod(ctx, &ExampleMethodRequest{})
+				if err != nil {
+					results[i] = err.Error()
+					return
+				}
+				results[i] = res.GetRes()
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, []string{"secure", "secure", "secure"}, results)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		t.Parallel()
+
+		untrustedCreds := credentials.NewTLS(&tls.Config{RootCAs: x509.NewCertPool(), ServerName: "grpcmock.test"})
+		_, client := StartExampleServiceMockServer(t, mocker.WithTLS(serverCreds, untrustedCreds))
+		_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+
+	t.Run("insecure client", func(t *testing.T) {
+		t.Parallel()
+
+		_, client := StartExampleServiceMockServer(t, mocker.WithTLS(serverCreds, insecure.NewCredentials()))
+		_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestScenarios(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := StartExampleServiceMockServer(t)
+	configure := testServer.Configure().ExampleMethod()
+	configure.DefaultReturn(nil, status.Error(codes.FailedPrecondition, "unexpected"))
+	configure.On(mocker.Any(), &ExampleMethodRequest{Req: "create"}).InScenario("account").
+		Return(&ExampleMethodResponse{Res: "created"}, nil).TransitionTo("created")
+	configure.On(mocker.Any(), &ExampleMethodRequest{Req: "get"}).InScenario("account").InState(mocker.ScenarioStarted).
+		Return(nil, status.Error(codes.NotFound, "no account"))
+	configure.On(mocker.Any(), &ExampleMethodRequest{Req: "get"}).InScenario("account").InState("created").
+		Return(&ExampleMethodResponse{Res: "account"}, nil)
+	configure.On(mocker.Any(), &ExampleMethodRequest{Req: "delete"}).InScenario("account").InState("created").
+		Return(&ExampleMethodResponse{Res: "deleted"}, nil).TransitionTo(mocker.ScenarioStarted)
+
+	get := func() (string, codes.Code) {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "get"})
+		return res.GetRes(), status.Code(err)
+	}
+
+	_, code := get()
+	assert.Equal(t, codes.NotFound, code)
+	_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "create"})
+	require.NoError(t, err)
+	assert.Equal(t, "created", testServer.mocker.ScenarioState("account"))
+	res, code := get()
+	assert.Equal(t, codes.OK, code)
+	assert.Equal(t, "account", res)
+
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "delete"})
+	require.NoError(t, err)
+	_, code = get()
+	assert.Equal(t, codes.NotFound, code)
+	// Deleting is gated on the created state
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "delete"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	testServer.mocker.SetScenarioState("account", "created")
+	assert.Equal(t, map[string]string{"account": "created"}, testServer.mocker.Scenarios())
+	_, code = get()
+	assert.Equal(t, codes.OK, code)
+	testServer.mocker.ResetScenarios()
+	assert.Equal(t, mocker.ScenarioStarted, testServer.mocker.ScenarioState("account"))
+	assert.Empty(t, testServer.mocker.Scenarios())
+}
+
+func TestScenariosStubs(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "account.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - request: {req: create}
+    response: {res: created}
+    transitionTo: created
+  - request: {req: get}
+    state: created
+    response: {res: account}
+  - request: {req: delete}
+    state: created
+    response: {res: deleted}
+    transitionTo: deleted
+  - request: {req: get}
+    state: deleted
+    status: {code: NOT_FOUND, message: deleted}
+`), 0o644))
+	testServer, client := startStubsMockServer(t, stubsDir)
+
+	_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "get"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.ErrorContains(t, err, `scenario "" is in state "Started", not "created"`)
+
+	for _, step := range []struct {
+		req         string
+		expectedRes string
+	}{{"create", "created"}, {"get", "account"}, {"delete", "deleted"}} {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: step.req})
+		require.NoError(t, err, step.req)
+		assert.Equal(t, step.expectedRes, res.GetRes())
+	}
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "get"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "deleted", testServer.mocker.ScenarioState(""))
+}
+
+func TestScenariosConcurrentCalls(t *testing.T) {
+	t.Parallel()
+
+	const calls = 20
+	concurrently := func(call func() error) int64 {
+		var matched atomic.Int64
+		var wg sync.WaitGroup
+		for range calls {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if call() == nil {
+					matched.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		return matched.Load()
+	}
+
+	t.Run("expectations", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := context.Background()
+		testServer, client := StartExampleServiceMockServer(t)
+		configure := testServer.Configure().ExampleMethod()
+		configure.DefaultReturn(nil, status.Error(codes.FailedPrecondition, "taken"))
+		configure.On(mocker.Any(), mocker.Any()).InScenario("token").InState(mocker.ScenarioStarted).
+			Return(&ExampleMethodResponse{Res: "token"}, nil).TransitionTo("taken")
+
+		matched := concurrently(func() error {
+			_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "take"})
+			return err
+		})
+		assert.EqualValues(t, 1, matched)
+		assert.Equal(t, "taken", testServer.mocker.ScenarioState("token"))
+	})
+
+	t.Run("stubs", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := context.Background()
+		stubsDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "token.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - request: {req: take}
+    scenario: token
+    state: Started
+    response: {res: token}
+    transitionTo: taken
+`), 0o644))
+		testServer, client := startStubsMockServer(t, stubsDir)
+
+		matched := concurrently(func() error {
+			_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "take"})
+			return err
+		})
+		assert.EqualValues(t, 1, matched)
+		assert.Equal(t, "taken", testServer.mocker.ScenarioState("token"))
+	})
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/grpcmock"
+	"github.com/torqio/grpcmock/pkg/mocker"
+)
+
+func TestServerSharedMocker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	srv := grpcmock.NewServer(t)
+	exampleServer := grpcmock.Add(srv, NewExampleServiceMockServerWithMocker)
+	unaryServer := grpcmock.Add(srv, NewUnaryOnlySvcMockServerWithMocker)
+
+	// Both services have a method named ExampleMethod, the full method names keep them apart
+	exampleServer.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "example"}).Return(&ExampleMethodResponse{Res: "example"}, nil)
+	unaryServer.Configure().ExampleMethod().On(mocker.Any(), &UnaryReq{Req: "unary"}).Return(&UnaryRes{Res: "unary"}, nil)
+
+	unaryRes, err := NewUnaryOnlySvcClient(srv.Conn()).ExampleMethod(ctx, &UnaryReq{Req: "unary"})
+	require.NoError(t, err)
+	assert.Equal(t, "unary", unaryRes.GetRes())
+
+	exampleRes, err := NewExampleServiceClient(srv.Conn()).ExampleMethod(ctx, &ExampleMethodRequest{Req: "example"})
+	require.NoError(t, err)
+	assert.Equal(t, "example", exampleRes.GetRes())
+
+	assert.Equal(t, 1, exampleServer.Configure().ExampleMethod().TimesCalled())
+	assert.Equal(t, 1, unaryServer.Configure().ExampleMethod().TimesCalled())
+
+	calls := srv.Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "/grpcmock.example.UnaryOnlySvc/ExampleMethod", calls[0].Method)
+	assert.Equal(t, "/grpcmock.example.ExampleService/ExampleMethod", calls[1].Method)
+	assert.True(t, calls[0].Matched)
+	assert.True(t, srv.AssertExpectations())
+
+	srv.ResetAll()
+	assert.Empty(t, srv.Calls())
+	assert.Equal(t, 0, exampleServer.Configure().ExampleMethod().TimesCalled())
+	assert.Equal(t, 0, unaryServer.Configure().ExampleMethod().TimesCalled())
+}
+
+func TestServerAssertExpectations(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcmock.NewServer(t)
+	exampleServer := grpcmock.Add(srv, NewExampleServiceMockServerWithMocker)
+	exampleServer.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "never-called"}).Return(&ExampleMethodResponse{}, nil)
+
+	reporter := &recordingReporter{}
+	assert.False(t, srv.Mocker().AssertExpectations(reporter))
+	require.Len(t, reporter.errors, 1)
+	assert.Contains(t, reporter.errors[0], "/grpcmock.example.ExampleService/ExampleMethod")
+	assert.Contains(t, reporter.errors[0], "was never called")
+}
+
+// recordingReporter is a mocker.TestReporter recording the reported errors, instead of failing the test
+type recordingReporter struct {
+	errors []string
+}
+
+func (r *recordingReporter) Helper() {}
+
+func (r *recordingReporter) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockerMethodDescriptors(t *testing.T) {
+	t.Parallel()
+
+	srv := grpcmock.NewServer(t)
+	grpcmock.Add(srv, NewExampleServiceMockServerWithMocker)
+	grpcmock.Add(srv, NewUnaryOnlySvcMockServerWithMocker)
+
+	var names []string
+	for _, md := range srv.Mocker().Methods() {
+		names = append(names, mocker.MethodName(md))
+	}
+	assert.Equal(t, []string{
+		"/grpcmock.example.ExampleService/ExampleMethod",
+		"/grpcmock.example.ExampleService/ExampleStreamRequest",
+		"/grpcmock.example.ExampleService/ExampleStreamRequestResponse",
+		"/grpcmock.example.ExampleService/ExampleStreamResponse",
+		"/grpcmock.example.UnaryOnlySvc/ExampleMethod",
+	}, names)
+
+	md, ok := srv.Mocker().MethodDescriptor(_ExampleService_ExampleMethodMethodName)
+	require.True(t, ok)
+	assert.Equal(t, (&ExampleMethodRequest{}).ProtoReflect().Descriptor().FullName(), md.Input().FullName())
+	assert.Equal(t, "ExampleMethod", mocker.MethodShortName(_ExampleService_ExampleMethodMethodName))
+}
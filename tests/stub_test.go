@@ -0,0 +1,880 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"github.com/torqio/grpcmock/pkg/stub"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startStubsMockServer creates a mock server with the file stubs in stubsDir and serves it
+func startStubsMockServer(t *testing.T, stubsDir string) (*ExampleServiceMockServer, ExampleServiceClient) {
+	t.Helper()
+
+	testServer, err := NewExampleServiceMockServerWithStubs(stubsDir)
+	require.NoError(t, err)
+	harness := mocker.NewHarness(t)
+	harness.Register(testServer)
+	return testServer, NewExampleServiceClient(harness.Conn())
+}
+
+func TestStubsLookupChain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "stubbed", "ExampleMethod", `{"req": "stubbed"}`, `{"res": "from-stub"}`)
+	writeStub(t, stubsDir, "both", "ExampleMethod", `{"req": "both"}`, `{"res": "from-stub"}`)
+	testServer, client := startStubsMockServer(t, stubsDir)
+
+	testServer.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "both"}).Return(&ExampleMethodResponse{Res: "from-expected-call"}, nil)
+	testServer.Configure().ExampleMethod().DefaultReturn(&ExampleMethodResponse{Res: "from-default"}, nil)
+
+	tests := []struct {
+		req         string
+		expectedRes string
+	}{
+		{req: "both", expectedRes: "from-expected-call"},
+		{req: "stubbed", expectedRes: "from-stub"},
+		{req: "other", expectedRes: "from-default"},
+	}
+	for _, tc := range tests {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: tc.req})
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedRes, res.GetRes(), tc.req)
+	}
+	assert.Equal(t, len(tests), testServer.Configure().ExampleMethod().TimesCalled())
+}
+
+func TestStubsStreaming(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "stream response", "ExampleStreamResponse", `{"req": "stubbed"}`, `{"res": "stream-response-stub"}`)
+	writeStub(t, stubsDir, "stream request", "ExampleStreamRequest", `{"req": "stubbed"}`, `{"res": "stream-request-stub"}`)
+	writeStub(t, stubsDir, "bidi", "ExampleStreamRequestResponse", `{"req": "stubbed"}`, `{"res": "bidi-stub"}`)
+	testServer, client := startStubsMockServer(t, stubsDir)
+	testServer.Configure().ExampleStreamRequest().DefaultReturn(&ExampleMethodResponse{Res: "stream-request-default"}, nil)
+	testServer.Configure().ExampleStreamRequestResponse().DefaultReturn([]*ExampleMethodResponse{{Res: "bidi-default"}}, nil)
+
+	t.Run("server streaming", func(t *testing.T) {
+		stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "stubbed"})
+		require.NoError(t, err)
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "stream-response-stub", res.GetRes())
+		_, err = stream.Recv()
+		assert.True(t, errors.Is(err, io.EOF))
+	})
+
+	t.Run("client streaming", func(t *testing.T) {
+		stream, err := client.ExampleStreamRequest(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "first"}))
+		require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "stubbed"}))
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		assert.Equal(t, "stream-request-stub", res.GetRes())
+	})
+
+	t.Run("bidi streaming", func(t *testing.T) {
+		stream, err := client.ExampleStreamRequestResponse(ctx)
+		require.NoError(t, err)
+		for req, expectedRes := range map[string]string{"stubbed": "bidi-stub", "other": "bidi-default"} {
+			require.NoError(t, stream.Send(&ExampleMethodRequest{Req: req}))
+			res, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, expectedRes, res.GetRes())
+		}
+		require.NoError(t, stream.CloseSend())
+		_, err = stream.Recv()
+		assert.True(t, errors.Is(err, io.EOF))
+	})
+}
+
+func TestStubsCasesFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "example.stubs.yaml"), []byte(`
+method: /grpcmock.example.ExampleService/ExampleMethod
+cases:
+  - name: first
+    request: {req: first}
+    response: {res: first-case}
+  - name: catch all
+    response: {res: catch-all-case}
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "stream.stubs.json"), []byte(`{
+  "method": "ExampleStreamResponse",
+  "cases": [{"request": {"req": "json"}, "response": {"res": "json-case"}}]
+}`), 0o644))
+	writeStub(t, stubsDir, "pair", "ExampleStreamResponse", `{"req": "pair"}`, `{"res": "pair-stub"}`)
+	_, client := startStubsMockServer(t, stubsDir)
+
+	for req, expectedRes := range map[string]string{"first": "first-case", "other": "catch-all-case"} {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: req})
+		require.NoError(t, err)
+		assert.Equal(t, expectedRes, res.GetRes(), req)
+	}
+
+	for req, expectedRes := range map[string]string{"json": "json-case", "pair": "pair-stub"} {
+		stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: req})
+		require.NoError(t, err)
+		res, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, expectedRes, res.GetRes(), req)
+	}
+}
+
+func TestStubsValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		expectedErr string
+	}{
+		{
+			name:        "unknown request field",
+			file:        "example.stubs.yaml",
+			content:     "method: ExampleMethod\ncases:\n  - request: {unknown: value}\n",
+			expectedErr: "invalid request for grpcmock.example.ExampleMethodRequest",
+		},
+		{
+			name:        "unknown response field",
+			file:        "example.stubs.json",
+			content:     `{"method": "ExampleMethod", "cases": [{"response": {"unknown": "value"}}]}`,
+			expectedErr: "invalid response for grpcmock.example.ExampleMethodResponse",
+		},
+		{
+			name:        "invalid status code",
+			file:        "example.stubs.yaml",
+			content:     "method: ExampleMethod\ncases:\n  - status: {code: NOT_A_CODE}\n",
+			expectedErr: `invalid status code "NOT_A_CODE"`,
+		},
+		{
+			name:        "unknown case field",
+			file:        "example.stubs.yaml",
+			content:     "method: ExampleMethod\ncases:\n  - responses: {res: value}\n",
+			expectedErr: `unknown field "responses"`,
+		},
+		{
+			name:        "invalid delay",
+			file:        "example.stubs.yaml",
+			content:     "method: ExampleMethod\ncases:\n  - delay: soon\n",
+			expectedErr: "invalid duration",
+		},
+		{
+			name:        "invalid match mode",
+			file:        "example.stubs.yaml",
+			content:     "method: ExampleMethod\nmatch: fuzzy\ncases:\n  - response: {res: value}\n",
+			expectedErr: `invalid match mode "fuzzy"`,
+		},
+		{
+			name:        "invalid regex",
+			file:        "example.stubs.yaml",
+			content:     "method: ExampleMethod\ncases:\n  - request: {req: \"$regex:(\"}\n",
+			expectedErr: "invalid regex",
+		},
+		{
+			name:        "invalid CEL expression",
+			file:        "example.stubs.yaml",
+			content:     "method: ExampleMethod\ncases:\n  - cel: request.unknown == 1\n",
+			expectedErr: "invalid CEL expression",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stubsDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(stubsDir, tc.file), []byte(tc.content), 0o644))
+			_, err := NewExampleServiceMockServerWithStubs(stubsDir)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+
+	t.Run("stubs of other services are not served", func(t *testing.T) {
+		ctx := context.Background()
+		stubsDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "other.stubs.yaml"), []byte("method: /other.Service/ExampleMethod\ncases:\n  - request: {req: other}\n    response: {res: from-other-service}\n"), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "example.stubs.yaml"), []byte("method: /grpcmock.example.ExampleService/ExampleMethod\ncases:\n  - request: {req: example}\n    response: {res: from-example-service}\n"), 0o644))
+		_, client := startStubsMockServer(t, stubsDir)
+
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "example"})
+		require.NoError(t, err)
+		assert.Equal(t, "from-example-service", res.GetRes())
+
+		_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "other"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.NotContains(t, err.Error(), "other.stubs.yaml")
+	})
+}
+
+func TestStubsMatchModes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "example.stubs.yaml"), []byte(`
+method: ExampleMethod
+match: exact
+cases:
+  - name: exact
+    request: {req: exact}
+    response: {res: exact-case}
+  - name: regex
+    request: {req: "$regex:^acc-[0-9]+$"}
+    response: {res: regex-case}
+  - name: jsonpath
+    jsonpath: {"$.req": "jsonpath"}
+    match: subset
+    response: {res: jsonpath-case}
+  - name: cel
+    cel: request.req.startsWith("cel-") && metadata["x-tenant"] == "acme"
+    match: subset
+    response: {res: cel-case}
+  - name: absent
+    request: {req: "$absent"}
+    response: {res: absent-case}
+  - name: any with priority
+    request: {req: "$any"}
+    priority: -1
+    response: {res: any-case}
+`), 0o644))
+	_, client := startStubsMockServer(t, stubsDir)
+
+	for req, expectedRes := range map[string]string{
+		"exact":    "exact-case",
+		"acc-1234": "regex-case",
+		"jsonpath": "jsonpath-case",
+		"":         "absent-case",
+		"cel-1":    "any-case", // No x-tenant metadata
+		"other":    "any-case",
+	} {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: req})
+		require.NoError(t, err)
+		assert.Equal(t, expectedRes, res.GetRes(), req)
+	}
+
+	res, err := client.ExampleMethod(metadata.AppendToOutgoingContext(ctx, "x-tenant", "acme"), &ExampleMethodRequest{Req: "cel-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "cel-case", res.GetRes())
+
+	t.Run("priority orders matching", func(t *testing.T) {
+		stubsDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "example.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - response: {res: first}
+  - response: {res: prioritized}
+    priority: 1
+`), 0o644))
+		_, client := startStubsMockServer(t, stubsDir)
+
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "any"})
+		require.NoError(t, err)
+		assert.Equal(t, "prioritized", res.GetRes())
+	})
+
+	t.Run("no match report", func(t *testing.T) {
+		stubsDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "example.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - name: exact
+    match: exact
+    request: {req: exact}
+  - name: regex
+    request: {req: "$regex:^acc-"}
+`), 0o644))
+		_, client := startStubsMockServer(t, stubsDir)
+
+		_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "other"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `case #0 ("exact"): $.req: expected "exact", got "other"`)
+		assert.Contains(t, err.Error(), `case #1 ("regex"): $.req: "other" doesn't match "$regex:^acc-"`)
+	})
+}
+
+func TestStubsStoreReload(t *testing.T) {
+	t.Parallel()
+
+	for name, opts := range map[string][]stub.StoreOption{
+		"notified": nil,
+		"polling":  {stub.WithPollInterval(10 * time.Millisecond)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stubsDir := t.TempDir()
+			writeStub(t, stubsDir, "live", "ExampleMethod", `{}`, `{"res": "v1"}`)
+
+			reloads := make(chan error, 10)
+			store, err := stub.NewStore(stubsDir, append(opts,
+				stub.WithServices(File_svc_proto.Services().ByName("ExampleService")),
+				stub.WithReloadHandler(func(err error) { reloads <- err }),
+			)...)
+			require.NoError(t, err)
+			go store.Watch(ctx)
+
+			testServer := NewExampleServiceMockServerWithMocker(mocker.NewMocker())
+			testServer.SetStubStore(store)
+			harness := mocker.NewHarness(t)
+			harness.Register(testServer)
+			client := NewExampleServiceClient(harness.Conn())
+
+			expectResponse := func(expected string) {
+				t.Helper()
+				res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{})
+				require.NoError(t, err)
+				assert.Equal(t, expected, res.GetRes())
+			}
+			waitReload := func() error {
+				t.Helper()
+				select {
+				case err := <-reloads:
+					return err
+				case <-time.After(5 * time.Second):
+					t.Fatal("stubs weren't reloaded")
+					return nil
+				}
+			}
+			expectResponse("v1")
+
+			// Letting the watcher start before changing the stubs
+			time.Sleep(100 * time.Millisecond)
+			writeStub(t, stubsDir, "live", "ExampleMethod", `{}`, `{"res": "v2"}`)
+			require.NoError(t, waitReload())
+			expectResponse("v2")
+
+			// An invalid stub is reported, and the previous stubs are kept
+			require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "invalid.stubs.yaml"), []byte("method: ExampleMethod\ncases:\n  - response: {unknown: value}\n"), 0o644))
+			err = waitReload()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "invalid response for grpcmock.example.ExampleMethodResponse")
+			assert.Equal(t, err, store.Err())
+			expectResponse("v2")
+		})
+	}
+}
+
+func TestStubsLint(t *testing.T) {
+	t.Parallel()
+
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "example.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - name: specific
+    request: {req: specific}
+  - name: catch all
+    request: {}
+  - name: shadowed
+    request: {req: other}
+  - name: prioritized
+    request: {req: prioritized}
+    priority: 1
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "other.stubs.yaml"), []byte(`
+method: ExampleStreamResponse
+cases:
+  - name: first
+    request: {req: "$regex:^a"}
+  - name: duplicate
+    request: {req: "$regex:^a"}
+  - name: invalid
+    request: {unknown: value}
+`), 0o644))
+	writeStub(t, stubsDir, "unknown", "UnknownMethod", `{}`, `{}`)
+	stubs, err := stub.MapStubFiles(stubsDir)
+	require.NoError(t, err)
+
+	var issues []string
+	for _, issue := range stub.Lint(stubs, File_svc_proto.Services().ByName("ExampleService")) {
+		issues = append(issues, strings.ReplaceAll(issue.String(), stubsDir+string(filepath.Separator), ""))
+	}
+	require.Len(t, issues, 4)
+	assert.Equal(t, `stub "example.stubs.yaml" case #2 ("shadowed"): never matched, as stub "example.stubs.yaml" case #1 ("catch all") is matched first for every request it matches`, issues[0])
+	assert.Contains(t, issues[1], `stub "other.stubs.yaml" case #2 ("invalid"): invalid for grpcmock.example.ExampleService.ExampleStreamResponse: invalid request for grpcmock.example.ExampleMethodRequest`)
+	assert.Equal(t, `stub "other.stubs.yaml" case #1 ("duplicate"): duplicate of stub "other.stubs.yaml" case #0 ("first"), which is matched first`, issues[2])
+	assert.Equal(t, `stub "unknown__UnknownMethod__request.json": unknown method "UnknownMethod"`, issues[3])
+
+	t.Run("scenario states", func(t *testing.T) {
+		scenarioDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(scenarioDir, "example.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - name: first call
+    request: {req: same}
+    scenario: flow
+    state: Started
+    transitionTo: done
+  - name: next calls
+    request: {req: same}
+    scenario: flow
+    state: done
+  - name: duplicate of next calls
+    request: {req: same}
+    scenario: flow
+    state: done
+`), 0o644))
+		stubs, err := stub.MapStubFiles(scenarioDir)
+		require.NoError(t, err)
+
+		issues := stub.Lint(stubs, File_svc_proto.Services().ByName("ExampleService"))
+		require.Len(t, issues, 1)
+		assert.Contains(t, issues[0].String(), `case #2 ("duplicate of next calls"): duplicate of stub`)
+		assert.Contains(t, issues[0].String(), `case #1 ("next calls")`)
+	})
+
+	t.Run("services sharing a method name", func(t *testing.T) {
+		servicesDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(servicesDir, "example.stubs.yaml"), []byte(`
+method: /grpcmock.example.ExampleService/ExampleMethod
+cases:
+  - request: {req: same}
+`), 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(servicesDir, "unary.stubs.yaml"), []byte(`
+method: /grpcmock.example.UnaryOnlySvc/ExampleMethod
+cases:
+  - request: {req: same}
+`), 0o644))
+		stubs, err := stub.MapStubFiles(servicesDir)
+		require.NoError(t, err)
+
+		issues := stub.Lint(stubs, File_svc_proto.Services().ByName("ExampleService"), File_svc_unary_only_proto.Services().ByName("UnaryOnlySvc"))
+		assert.Empty(t, issues)
+	})
+}
+
+func TestStubsStatusAndMetadata(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	writeStub(t, stubsDir, "not found", "ExampleMethod", `{"req": "missing"}`, `{
+  "status": {
+    "code": "NOT_FOUND",
+    "message": "resource missing",
+    "details": [{"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "MISSING", "domain": "example.com"}]
+  }
+}`)
+	writeStub(t, stubsDir, "plain", "ExampleMethod", `{"req": "plain"}`, `{"res": "plain-response"}`)
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "example.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - request: {req: metadata}
+    response: {res: with-metadata}
+    headers: {x-header: header-value}
+    trailers: {x-trailer: trailer-value}
+    delay: 50ms
+`), 0o644))
+	writeStub(t, stubsDir, "stream failure", "ExampleStreamResponse", `{"req": "failing"}`, `{"status": {"code": "UNAVAILABLE", "message": "try later"}}`)
+	_, client := startStubsMockServer(t, stubsDir)
+
+	t.Run("status with details", func(t *testing.T) {
+		_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "missing"})
+		st := status.Convert(err)
+		assert.Equal(t, codes.NotFound, st.Code())
+		assert.Equal(t, "resource missing", st.Message())
+		require.Len(t, st.Details(), 1)
+		errorInfo, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, "MISSING", errorInfo.GetReason())
+	})
+
+	t.Run("plain response", func(t *testing.T) {
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "plain"})
+		require.NoError(t, err)
+		assert.Equal(t, "plain-response", res.GetRes())
+	})
+
+	t.Run("headers, trailers and delay", func(t *testing.T) {
+		var header, trailer metadata.MD
+		start := time.Now()
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "metadata"}, grpc.Header(&header), grpc.Trailer(&trailer))
+		require.NoError(t, err)
+		assert.Equal(t, "with-metadata", res.GetRes())
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, []string{"header-value"}, header.Get("x-header"))
+		assert.Equal(t, []string{"trailer-value"}, trailer.Get("x-trailer"))
+	})
+
+	t.Run("streaming status", func(t *testing.T) {
+		stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "failing"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
+
+func TestStubsStreamingCases(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "stream-response.stubs.yaml"), []byte(`
+method: ExampleStreamResponse
+cases:
+  - request: {req: stream}
+    messages:
+      - message: {res: first}
+      - message: {res: second}
+        delay: 50ms
+    status: {code: ABORTED, message: stream ended}
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "stream-request.stubs.yaml"), []byte(`
+method: ExampleStreamRequest
+cases:
+  - requests: [{req: first}, {req: second}]
+    response: {res: sequence-matched}
+`), 0o644))
+	writeStub(t, stubsDir, "bidi", "ExampleStreamRequestResponse", `[{"req": "ping"}, {"req": "pong"}]`, `{"messages": [{"message": {"res": "one"}}, {"message": {"res": "two"}}]}`)
+	_, client := startStubsMockServer(t, stubsDir)
+
+	t.Run("server streaming messages and status", func(t *testing.T) {
+		start := time.Now()
+		stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "stream"})
+		require.NoError(t, err)
+		for _, expectedRes := range []string{"first", "second"} {
+			res, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, expectedRes, res.GetRes())
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Aborted, status.Code(err))
+	})
+
+	t.Run("client streaming sequence", func(t *testing.T) {
+		stream, err := client.ExampleStreamRequest(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "first"}))
+		require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "second"}))
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		assert.Equal(t, "sequence-matched", res.GetRes())
+
+		stream, err = client.ExampleStreamRequest(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "first"}))
+		_, err = stream.CloseAndRecv()
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("bidi streaming sequence", func(t *testing.T) {
+		stream, err := client.ExampleStreamRequestResponse(ctx)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "ping"}))
+		require.NoError(t, stream.Send(&ExampleMethodRequest{Req: "pong"}))
+		require.NoError(t, stream.CloseSend())
+		for _, expectedRes := range []string{"one", "two"} {
+			res, err := stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, expectedRes, res.GetRes())
+		}
+		_, err = stream.Recv()
+		assert.True(t, errors.Is(err, io.EOF))
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		invalidDir := t.TempDir()
+		writeStub(t, invalidDir, "unary", "ExampleMethod", `{}`, `{"messages": [{"message": {"res": "one"}}]}`)
+		_, err := NewExampleServiceMockServerWithStubs(invalidDir)
+		assert.ErrorContains(t, err, "stream messages can only be sent by server streaming methods")
+	})
+}
+
+func TestStubsTemplates(t *testing.T) {
+	t.Parallel()
+
+	stubsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(stubsDir, "templates.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - request: {req: echo}
+    response: {res: 'echo {{ .Request.req }} from {{ .Metadata.user }}'}
+  - request: {req: generated}
+    response: {res: '{{ uuid }}|{{ now }}'}
+`), 0o644))
+	writeStub(t, stubsDir, "stream", "ExampleStreamResponse", `{}`, `{"messages": [{"message": {"res": "{{ .Request.req | upper }}"}}]}`)
+	_, client := startStubsMockServer(t, stubsDir)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "user", "alice")
+
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "echo"})
+	require.NoError(t, err)
+	assert.Equal(t, "echo echo from alice", res.GetRes())
+
+	res, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "generated"})
+	require.NoError(t, err)
+	generated := strings.Split(res.GetRes(), "|")
+	require.Len(t, generated, 2)
+	assert.Len(t, generated[0], 36)
+	_, err = time.Parse(time.RFC3339Nano, generated[1])
+	assert.NoError(t, err)
+
+	stream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "shout"})
+	require.NoError(t, err)
+	streamRes, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "SHOUT", streamRes.GetRes())
+
+	t.Run("quoted values", func(t *testing.T) {
+		quotedDir := t.TempDir()
+		writeStub(t, quotedDir, "quoted", "ExampleMethod", `{}`, `{"res": {{ printf "echo %s" .Request.req | json }}}`)
+		_, client := startStubsMockServer(t, quotedDir)
+
+		req := `say "hi", "res": \ injected`
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: req})
+		require.NoError(t, err)
+		assert.Equal(t, "echo "+req, res.GetRes())
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		invalidDir := t.TempDir()
+		writeStub(t, invalidDir, "invalid", "ExampleMethod", `{}`, `{"res": "{{ .Request.req "}`)
+		_, err := NewExampleServiceMockServerWithStubs(invalidDir)
+		assert.ErrorContains(t, err, "parse response template")
+	})
+}
+
+// startRecordUpstream serves a mock server with file stubs for all the methods, to record calls forwarded to it
+func startRecordUpstream(t *testing.T) (*ExampleServiceMockServer, *grpc.ClientConn) {
+	t.Helper()
+
+	upstreamDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "upstream.stubs.yaml"), []byte(`
+method: ExampleMethod
+cases:
+  - request: {req: known}
+    response: {res: upstream}
+    headers: {x-upstream: "yes"}
+  - request: {req: missing}
+    status: {code: NOT_FOUND, message: no such thing}
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(upstreamDir, "upstream-streams.stubs.yaml"), []byte(`
+method: ExampleStreamResponse
+cases:
+  - messages: [{message: {res: first}}, {message: {res: second}}]
+    status: {code: ABORTED, message: stream ended}
+`), 0o644))
+	writeStub(t, upstreamDir, "client", "ExampleStreamRequest", `[{"req": "a"}, {"req": "b"}]`, `{"res": "ab"}`)
+	writeStub(t, upstreamDir, "bidi", "ExampleStreamRequestResponse", `[{"req": "ping"}, {"req": "pong"}]`, `{"messages": [{"message": {"res": "one"}}, {"message": {"res": "two"}}]}`)
+	upstreamServer, err := NewExampleServiceMockServerWithStubs(upstreamDir)
+	require.NoError(t, err)
+	upstreamHarness := mocker.NewHarness(t)
+	upstreamHarness.Register(upstreamServer)
+	return upstreamServer, upstreamHarness.Conn()
+}
+
+// expectRecordedCalls calls every method with the requests stubbed by startRecordUpstream, and checks their responses
+func expectRecordedCalls(t *testing.T, client ExampleServiceClient) {
+	t.Helper()
+
+	ctx := context.Background()
+	var header metadata.MD
+	res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "known"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "upstream", res.GetRes())
+	assert.Equal(t, []string{"yes"}, header.Get("x-upstream"))
+
+	_, err = client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "no such thing", status.Convert(err).Message())
+
+	serverStream, err := client.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "stream"})
+	require.NoError(t, err)
+	for _, expectedRes := range []string{"first", "second"} {
+		res, err := serverStream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, expectedRes, res.GetRes())
+	}
+	_, err = serverStream.Recv()
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	clientStream, err := client.ExampleStreamRequest(ctx)
+	require.NoError(t, err)
+	require.NoError(t, clientStream.Send(&ExampleMethodRequest{Req: "a"}))
+	require.NoError(t, clientStream.Send(&ExampleMethodRequest{Req: "b"}))
+	res, err = clientStream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, "ab", res.GetRes())
+
+	bidiStream, err := client.ExampleStreamRequestResponse(ctx)
+	require.NoError(t, err)
+	require.NoError(t, bidiStream.Send(&ExampleMethodRequest{Req: "ping"}))
+	require.NoError(t, bidiStream.Send(&ExampleMethodRequest{Req: "pong"}))
+	require.NoError(t, bidiStream.CloseSend())
+	for _, expectedRes := range []string{"one", "two"} {
+		res, err := bidiStream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, expectedRes, res.GetRes())
+	}
+	_, err = bidiStream.Recv()
+	assert.True(t, errors.Is(err, io.EOF))
+}
+
+func TestStubsRecord(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	upstreamServer, upstreamConn := startRecordUpstream(t)
+	recordDir := t.TempDir()
+	recordingServer, client := startStubsMockServer(t, recordDir)
+	recordingServer.SetRecorder(stub.NewRecorder(upstreamConn, recordDir))
+	recordingServer.Configure().ExampleMethod().On(mocker.Any(), &ExampleMethodRequest{Req: "expected"}).Return(&ExampleMethodResponse{Res: "local"}, nil)
+
+	t.Run("forwards unmatched calls", func(t *testing.T) {
+		expectRecordedCalls(t, client)
+		// Recording a request again replaces its case
+		_, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "known"})
+		require.NoError(t, err)
+
+		// Expected calls aren't forwarded
+		res, err := client.ExampleMethod(ctx, &ExampleMethodRequest{Req: "expected"})
+		require.NoError(t, err)
+		assert.Equal(t, "local", res.GetRes())
+		assert.Equal(t, 3, upstreamServer.Configure().ExampleMethod().TimesCalled())
+	})
+
+	t.Run("recorded stubs", func(t *testing.T) {
+		casesFile, err := stub.LoadCasesFile(filepath.Join(recordDir, "grpcmock.example.ExampleService_ExampleMethod.stubs.json"))
+		require.NoError(t, err)
+		assert.Equal(t, "/grpcmock.example.ExampleService/ExampleMethod", casesFile.Method)
+		require.Len(t, casesFile.Cases, 2)
+		assert.JSONEq(t, `{"req": "known"}`, string(casesFile.Cases[0].Request))
+		assert.Equal(t, stub.MatchExact, casesFile.Cases[0].Match)
+		assert.Equal(t, map[string]string{"x-upstream": "yes"}, casesFile.Cases[0].Headers)
+		assert.Equal(t, &stub.Status{Code: "NOT_FOUND", Message: "no such thing"}, casesFile.Cases[1].Status)
+
+		casesFile, err = stub.LoadCasesFile(filepath.Join(recordDir, "grpcmock.example.ExampleService_ExampleStreamRequestResponse.stubs.json"))
+		require.NoError(t, err)
+		require.Len(t, casesFile.Cases, 1)
+		require.Len(t, casesFile.Cases[0].Requests, 2)
+		assert.Len(t, casesFile.Cases[0].Messages, 2)
+	})
+
+	t.Run("replays the recorded stubs", func(t *testing.T) {
+		_, replayClient := startStubsMockServer(t, recordDir)
+		expectRecordedCalls(t, replayClient)
+	})
+}
+
+func TestStubsRecordUpstreamClientStreamError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	upstreamServer := NewExampleServiceMockServerT(t)
+	upstreamServer.Configure().ExampleStreamRequest().On(&ExampleMethodRequest{Req: "fail"}, mocker.Any()).
+		Return(nil, status.Error(codes.Aborted, "upstream failed"))
+	upstreamHarness := mocker.NewHarness(t)
+	upstreamHarness.Register(upstreamServer)
+	upstreamConn := upstreamHarness.Conn()
+	recordDir := t.TempDir()
+	recordingServer, client := startStubsMockServer(t, recordDir)
+	recordingServer.SetRecorder(stub.NewRecorder(upstreamConn, recordDir))
+
+	clientStream, err := client.ExampleStreamRequest(ctx)
+	require.NoError(t, err)
+	require.NoError(t, clientStream.Send(&ExampleMethodRequest{Req: "a"}))
+	// The upstream server fails in the middle of the client stream
+	for _, req := range []string{"fail", "b", "c"} {
+		require.NoError(t, clientStream.Send(&ExampleMethodRequest{Req: req}))
+	}
+	_, err = clientStream.CloseAndRecv()
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	casesFile, err := stub.LoadCasesFile(filepath.Join(recordDir, "grpcmock.example.ExampleService_ExampleStreamRequest.stubs.json"))
+	require.NoError(t, err)
+	require.Len(t, casesFile.Cases, 1)
+	assert.Equal(t, &stub.Status{Code: "ABORTED", Message: "upstream failed"}, casesFile.Cases[0].Status)
+	require.GreaterOrEqual(t, len(casesFile.Cases[0].Requests), 2)
+	assert.JSONEq(t, `{"req": "a"}`, string(casesFile.Cases[0].Requests[0]))
+	assert.JSONEq(t, `{"req": "fail"}`, string(casesFile.Cases[0].Requests[1]))
+	// The rest of the client stream isn't served by the upstream server
+	assert.Equal(t, 2, upstreamServer.Configure().ExampleStreamRequest().TimesCalled())
+}
+
+func TestStubsCassette(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	_, upstreamConn := startRecordUpstream(t)
+	cassettePath := filepath.Join(t.TempDir(), "session.cassette.json")
+	recordingServer, client := startStubsMockServer(t, t.TempDir())
+	recordingServer.SetRecorder(stub.NewCassetteRecorder(upstreamConn, cassettePath))
+	expectRecordedCalls(t, client)
+
+	startReplayServer := func(t *testing.T) (*stub.Replayer, ExampleServiceClient) {
+		replayer, err := stub.LoadReplayer(cassettePath)
+		require.NoError(t, err)
+		testServer := NewExampleServiceMockServerWithMocker(mocker.NewMocker())
+		testServer.SetReplayer(replayer)
+		harness := mocker.NewHarness(t)
+		harness.Register(testServer)
+		return replayer, NewExampleServiceClient(harness.Conn())
+	}
+
+	t.Run("recorded session", func(t *testing.T) {
+		cassette, err := stub.LoadCassette(cassettePath)
+		require.NoError(t, err)
+		var methods []string
+		for _, interaction := range cassette.Interactions {
+			methods = append(methods, mocker.MethodShortName(interaction.Method))
+		}
+		assert.Equal(t, []string{"ExampleMethod", "ExampleMethod", "ExampleStreamResponse", "ExampleStreamRequest", "ExampleStreamRequestResponse"}, methods)
+	})
+
+	t.Run("replays in order", func(t *testing.T) {
+		replayer, replayClient := startReplayServer(t)
+		expectRecordedCalls(t, replayClient)
+		assert.NoError(t, replayer.Verify())
+	})
+
+	t.Run("diverged request", func(t *testing.T) {
+		replayer, replayClient := startReplayServer(t)
+		_, err := replayClient.ExampleMethod(ctx, &ExampleMethodRequest{Req: "other"})
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Contains(t, err.Error(), "call #0 diverged from the cassette")
+		assert.Contains(t, err.Error(), `"req": "known" => "other"`)
+
+		var diverged stub.ErrCassetteDiverged
+		require.True(t, errors.As(replayer.Verify(), &diverged))
+		assert.Equal(t, 0, diverged.Index)
+		assert.Equal(t, "/grpcmock.example.ExampleService/ExampleMethod", diverged.Method)
+	})
+
+	t.Run("diverged method", func(t *testing.T) {
+		_, replayClient := startReplayServer(t)
+		stream, err := replayClient.ExampleStreamResponse(ctx, &ExampleMethodRequest{Req: "stream"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.Contains(t, err.Error(), "expected a call of /grpcmock.example.ExampleService/ExampleMethod, got a call of /grpcmock.example.ExampleService/ExampleStreamResponse")
+	})
+
+	t.Run("unplayed interactions", func(t *testing.T) {
+		replayer, replayClient := startReplayServer(t)
+		_, err := replayClient.ExampleMethod(ctx, &ExampleMethodRequest{Req: "known"})
+		require.NoError(t, err)
+		assert.EqualError(t, replayer.Verify(), "4 interaction(s) of the cassette weren't replayed, starting with interaction #1 of /grpcmock.example.ExampleService/ExampleMethod")
+	})
+}
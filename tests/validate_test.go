@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/torqio/grpcmock/pkg/mocker"
+	"github.com/torqio/grpcmock/pkg/mocker/protovalidate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func startValidatedAccountService(t *testing.T) (*AccountServiceMockServer, AccountServiceClient) {
+	t.Helper()
+
+	testServer, client := StartAccountServiceMockServer(t)
+	testServer.mocker.SetValidator(protovalidate.Global())
+	return testServer, client
+}
+
+func requireBadRequest(t *testing.T, err error, fields ...string) {
+	t.Helper()
+
+	st := status.Convert(err)
+	require.Equal(t, codes.InvalidArgument, st.Code(), st.Message())
+	require.Len(t, st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(t, ok)
+	var violated []string
+	for _, violation := range badRequest.GetFieldViolations() {
+		assert.NotEmpty(t, violation.GetDescription())
+		assert.NotEmpty(t, violation.GetReason())
+		violated = append(violated, violation.GetField())
+	}
+	assert.ElementsMatch(t, fields, violated)
+}
+
+func TestValidateRequests(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := startValidatedAccountService(t)
+	configure := testServer.Configure()
+	configure.CreateAccount().DefaultReturn(&Account{Id: "1", Email: "a@example.com"}, nil)
+	configure.ImportAccounts().DefaultReturn(&Account{Id: "1", Email: "a@example.com"}, nil)
+	configure.ListAccounts().DefaultReturn([]*Account{{Id: "1", Email: "a@example.com"}}, nil)
+
+	account, err := client.CreateAccount(ctx, &CreateAccountRequest{Email: "a@example.com", Age: 18})
+	require.NoError(t, err)
+	assert.Equal(t, "1", account.GetId())
+
+	_, err = client.CreateAccount(ctx, &CreateAccountRequest{Email: "not an email", Age: 17})
+	requireBadRequest(t, err, "email", "age")
+	// Invalid requests are rejected before they're matched
+	assert.Equal(t, 1, configure.CreateAccount().TimesCalled())
+
+	importStream, err := client.ImportAccounts(ctx)
+	require.NoError(t, err)
+	require.NoError(t, importStream.Send(&CreateAccountRequest{Email: "a@example.com", Age: 30}))
+	require.NoError(t, importStream.Send(&CreateAccountRequest{Email: "b@example.com"}))
+	_, err = importStream.CloseAndRecv()
+	requireBadRequest(t, err, "age")
+
+	listStream, err := client.ListAccounts(ctx, &ListAccountsRequest{PageSize: 1000})
+	require.NoError(t, err)
+	_, err = listStream.Recv()
+	requireBadRequest(t, err, "page_size")
+
+	// Validation is opt-in
+	testServer.mocker.SetValidator(nil)
+	_, err = client.CreateAccount(ctx, &CreateAccountRequest{Email: "not an email"})
+	require.NoError(t, err)
+}
+
+func TestValidateResponses(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	testServer, client := startValidatedAccountService(t)
+	// The invalid responses are logged as errors, failing the test otherwise
+	testServer.mocker.SetT(nil)
+	configure := testServer.Configure()
+	configure.CreateAccount().On(mocker.Any(), &CreateAccountRequest{Email: "a@example.com", Age: 20}).
+		Return(&Account{Id: "1", Email: "invalid"}, nil)
+	configure.CreateAccount().On(mocker.Any(), &CreateAccountRequest{Email: "b@example.com", Age: 20}).
+		Return(nil, status.Error(codes.AlreadyExists, "exists"))
+	configure.ListAccounts().DefaultReturn([]*Account{{Id: "1", Email: "a@example.com"}, {Email: "b@example.com"}}, nil)
+
+	_, err := client.CreateAccount(ctx, &CreateAccountRequest{Email: "a@example.com", Age: 20})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.ErrorContains(t, err, "the configured response of method CreateAccount is invalid")
+	// Configured errors aren't validated
+	_, err = client.CreateAccount(ctx, &CreateAccountRequest{Email: "b@example.com", Age: 20})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	listStream, err := client.ListAccounts(ctx, &ListAccountsRequest{})
+	require.NoError(t, err)
+	_, err = listStream.Recv()
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.ErrorContains(t, err, "id")
+}